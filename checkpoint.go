@@ -0,0 +1,220 @@
+package neatgo
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sort"
+)
+
+// checkpoint is the saved state of a Population between two generations
+type checkpoint struct {
+	InputNumber      int
+	HiddenNumber     int
+	OutputNumber     int
+	GenomeNumber     int
+	FitnessThreshold float64
+	Options          *Options
+
+	Generation    int
+	Evaluated     bool
+	Distance      int
+	LastFitness   float64
+	Keep          int
+	NextSpeciesID int
+	NextNodeID    int
+
+	Evaluations     int
+	BestFitness     float64
+	LastImprovement int
+
+	NextInnovationID int64
+	Links            []checkpointLink
+	Splits           map[int64]int
+
+	RandState *uint64 `json:",omitempty"`
+
+	Genomes Genomes
+	Winners Genomes
+	Species []checkpointSpecies
+}
+
+type checkpointLink struct {
+	In         int
+	Out        int
+	Innovation int64
+}
+
+// Members are indexes into Genomes, saved only when the genomes have been
+// evaluated and not yet replaced by the next generation
+type checkpointSpecies struct {
+	ID              int
+	Representative  *Genome
+	Members         []int `json:",omitempty"`
+	Fitness         float64
+	AdjustedFitness float64
+	BestFitness     float64
+	LastImproved    int
+}
+
+// SaveCheckpoint writes the full state of the population: genomes, winners,
+// species, innovation registry, random generator, options and generation.
+// The state of a custom Options.Source is not saved.
+func (o *Population) SaveCheckpoint(w io.Writer) error {
+	cp := checkpoint{
+		InputNumber:      o.inputNumber,
+		HiddenNumber:     o.hiddenNumber,
+		OutputNumber:     o.outputNumber,
+		GenomeNumber:     o.genomeNumber,
+		FitnessThreshold: o.fitnessThreshold,
+		Options:          o.Options,
+		Generation:       o.generation,
+		Evaluated:        o.evaluated,
+		Distance:         o.dis,
+		LastFitness:      o.lastFitness,
+		Keep:             o.keep,
+		NextSpeciesID:    o.nextSpeciesID,
+		NextNodeID:       o.nextNodeID,
+		Evaluations:      o.evaluations,
+		BestFitness:      o.bestFitness,
+		LastImprovement:  o.lastImprovement,
+		NextInnovationID: o.nextInnovationID,
+		Splits:           o.innovations.splits,
+		Genomes:          o.genomes,
+		Winners:          o.Winners,
+	}
+	for k, v := range o.innovations.links {
+		cp.Links = append(cp.Links, checkpointLink{In: k[0], Out: k[1], Innovation: v})
+	}
+	sort.Slice(cp.Links, func(i, j int) bool { return cp.Links[i].Innovation < cp.Links[j].Innovation })
+	if o.source != nil {
+		state := o.source.state
+		cp.RandState = &state
+	}
+	index := make(map[*Genome]int, len(o.genomes))
+	for i, g := range o.genomes {
+		index[g] = i
+	}
+	for _, s := range o.Species {
+		cs := checkpointSpecies{
+			ID:              s.ID,
+			Representative:  s.Representative,
+			Fitness:         s.Fitness,
+			AdjustedFitness: s.AdjustedFitness,
+			BestFitness:     s.BestFitness,
+			LastImproved:    s.LastImproved,
+		}
+		if o.evaluated {
+			for _, g := range s.Members {
+				cs.Members = append(cs.Members, index[g])
+			}
+		}
+		cp.Species = append(cp.Species, cs)
+	}
+
+	return json.NewEncoder(w).Encode(&cp)
+}
+
+// LoadCheckpoint restores a state written by SaveCheckpoint, Run then
+// continues from the saved generation
+func (o *Population) LoadCheckpoint(r io.Reader) error {
+	cp := checkpoint{}
+	if err := json.NewDecoder(r).Decode(&cp); err != nil {
+		return err
+	}
+	if cp.Options == nil || len(cp.Genomes) == 0 {
+		return errors.New("neatgo: invalid checkpoint")
+	}
+
+	if o.Options != nil {
+		cp.Options.Source = o.Options.Source
+	}
+	o.inputNumber = cp.InputNumber
+	o.hiddenNumber = cp.HiddenNumber
+	o.outputNumber = cp.OutputNumber
+	o.genomeNumber = cp.GenomeNumber
+	o.fitnessThreshold = cp.FitnessThreshold
+	o.Options = cp.Options
+	o.generation = cp.Generation
+	o.evaluated = cp.Evaluated
+	o.dis = cp.Distance
+	o.lastFitness = cp.LastFitness
+	o.keep = cp.Keep
+	o.nextSpeciesID = cp.NextSpeciesID
+	o.nextNodeID = cp.NextNodeID
+	o.evaluations = cp.Evaluations
+	o.bestFitness = cp.BestFitness
+	o.lastImprovement = cp.LastImprovement
+	o.nextInnovationID = cp.NextInnovationID
+
+	o.innovations = newInnovationTracker()
+	for _, l := range cp.Links {
+		o.innovations.links[[2]int{l.In, l.Out}] = l.Innovation
+	}
+	for k, v := range cp.Splits {
+		o.innovations.splits[k] = v
+	}
+
+	o.initRand()
+	if o.source != nil && cp.RandState != nil {
+		o.source.state = *cp.RandState
+	}
+
+	for _, g := range cp.Genomes {
+		g.Population = o
+	}
+	for _, g := range cp.Winners {
+		g.Population = o
+	}
+	o.genomes = cp.Genomes
+	o.Winners = cp.Winners
+
+	o.Species = nil
+	for _, s := range cp.Species {
+		if s.Representative == nil {
+			return errors.New("neatgo: invalid checkpoint")
+		}
+		s.Representative.Population = o
+		species := &Species{
+			ID:              s.ID,
+			Representative:  s.Representative,
+			Members:         Genomes{},
+			Fitness:         s.Fitness,
+			AdjustedFitness: s.AdjustedFitness,
+			BestFitness:     s.BestFitness,
+			LastImproved:    s.LastImproved,
+		}
+		for _, i := range s.Members {
+			if i < 0 || i >= len(o.genomes) {
+				return errors.New("neatgo: invalid checkpoint")
+			}
+			species.Members = append(species.Members, o.genomes[i])
+		}
+		o.Species = append(o.Species, species)
+	}
+	return nil
+}
+
+// SaveCheckpointFile ...
+func (o *Population) SaveCheckpointFile(file string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err := o.SaveCheckpoint(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// LoadCheckpointFile ...
+func (o *Population) LoadCheckpointFile(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return o.LoadCheckpoint(f)
+}
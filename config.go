@@ -0,0 +1,460 @@
+package neatgo
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Config is the full configuration of a population
+type Config struct {
+	InputNumber      int
+	HiddenNumber     int
+	OutputNumber     int
+	GenomeNumber     int
+	FitnessThreshold float64
+	Options          *Options
+}
+
+// Validate ...
+func (o *Config) Validate() error {
+	switch {
+	case o.InputNumber < 1:
+		return fmt.Errorf("neatgo: InputNumber %d must be positive", o.InputNumber)
+	case o.OutputNumber < 1:
+		return fmt.Errorf("neatgo: OutputNumber %d must be positive", o.OutputNumber)
+	case o.GenomeNumber < 1:
+		return fmt.Errorf("neatgo: GenomeNumber %d must be positive", o.GenomeNumber)
+	case o.HiddenNumber < 0:
+		return fmt.Errorf("neatgo: HiddenNumber %d must not be negative", o.HiddenNumber)
+	case o.Options == nil:
+		return nil
+	case o.HiddenNumber > o.Options.MaxNode:
+		return fmt.Errorf("neatgo: HiddenNumber %d exceeds MaxNode %d", o.HiddenNumber, o.Options.MaxNode)
+	}
+	return o.Options.Validate()
+}
+
+// Validate checks that the probabilities are within [0, 1], that counts,
+// powers, stdevs and coefficients are not negative, that the compatibility
+// threshold is positive, that the min value of a gene does not exceed its max
+// value, that a mutated gene has a power, and that the named functions exist
+func (o *Options) Validate() error {
+	for _, p := range []struct {
+		name string
+		v    float64
+	}{
+		{"AddNode", o.AddNode},
+		{"AddConnection", o.AddConnection},
+		{"MutateWeight", o.MutateWeight},
+		{"DeleteNode", o.DeleteNode},
+		{"DeleteConnection", o.DeleteConnection},
+		{"ToggleEnable", o.ToggleEnable},
+		{"ReenableRate", o.ReenableRate},
+		{"ActivationMutateRate", o.ActivationMutateRate},
+		{"WeightReplaceRate", o.WeightReplaceRate},
+		{"BiasMutateRate", o.BiasMutateRate},
+		{"BiasReplaceRate", o.BiasReplaceRate},
+		{"ResponseMutateRate", o.ResponseMutateRate},
+		{"ResponseReplaceRate", o.ResponseReplaceRate},
+		{"AggregationMutateRate", o.AggregationMutateRate},
+		{"SurvivalThreshold", o.SurvivalThreshold},
+		{"CrossoverRate", o.CrossoverRate},
+	} {
+		if p.v < 0 || p.v > 1 || math.IsNaN(p.v) {
+			return fmt.Errorf("neatgo: %s %v must be within [0, 1]", p.name, p.v)
+		}
+	}
+
+	for _, p := range []struct {
+		name string
+		v    float64
+	}{
+		{"WeightInitStdev", o.WeightInitStdev},
+		{"WeightMutatePower", o.WeightMutatePower},
+		{"BiasInitStdev", o.BiasInitStdev},
+		{"BiasMutatePower", o.BiasMutatePower},
+		{"ResponseInitStdev", o.ResponseInitStdev},
+		{"ResponseMutatePower", o.ResponseMutatePower},
+		{"CompatibilityExcess", o.CompatibilityExcess},
+		{"CompatibilityDisjoint", o.CompatibilityDisjoint},
+		{"CompatibilityWeight", o.CompatibilityWeight},
+		{"KeepWinner", float64(o.KeepWinner)},
+		{"MaxDistance", float64(o.MaxDistance)},
+		{"MaxNode", float64(o.MaxNode)},
+		{"AddConnectionTries", float64(o.AddConnectionTries)},
+		{"CheckpointInterval", float64(o.CheckpointInterval)},
+		{"MaxStagnation", float64(o.MaxStagnation)},
+		{"SpeciesElitism", float64(o.SpeciesElitism)},
+		{"Elitism", float64(o.Elitism)},
+		{"WinnerPool", float64(o.WinnerPool)},
+	} {
+		if p.v < 0 || math.IsNaN(p.v) {
+			return fmt.Errorf("neatgo: %s %v must not be negative", p.name, p.v)
+		}
+	}
+
+	if !(o.CompatibilityThreshold > 0) {
+		return fmt.Errorf("neatgo: CompatibilityThreshold %v must be positive", o.CompatibilityThreshold)
+	}
+
+	for _, g := range []struct {
+		name        string
+		rate, power float64
+		min, max    float64
+	}{
+		{"Weight", o.MutateWeight, o.WeightMutatePower, o.WeightMinValue, o.WeightMaxValue},
+		{"Bias", o.BiasMutateRate, o.BiasMutatePower, o.BiasMinValue, o.BiasMaxValue},
+		{"Response", o.ResponseMutateRate, o.ResponseMutatePower, o.ResponseMinValue, o.ResponseMaxValue},
+	} {
+		if g.min > g.max {
+			return fmt.Errorf("neatgo: %sMinValue %v exceeds %sMaxValue %v", g.name, g.min, g.name, g.max)
+		}
+		if g.rate > 0 && g.power == 0 {
+			return fmt.Errorf("neatgo: %s mutate rate %v with %sMutatePower 0", g.name, g.rate, g.name)
+		}
+	}
+
+	for _, t := range []string{o.InitType, o.MutateType} {
+		if t != "" && t != "gaussian" && t != "uniform" {
+			return fmt.Errorf("neatgo: unknown distribution %q", t)
+		}
+	}
+	for _, name := range append([]string{o.HiddenActivation, o.OutputActivation}, o.Activations...) {
+		if _, ok := activateFunc[name]; name != "" && !ok {
+			return fmt.Errorf("neatgo: unknown activation function %q", name)
+		}
+	}
+	for _, name := range o.Aggregations {
+		if _, ok := aggregateFunc[name]; !ok {
+			return fmt.Errorf("neatgo: unknown aggregation function %q", name)
+		}
+	}
+	return nil
+}
+
+// NewPopulation ...
+func (o *Config) NewPopulation() (*Population, error) {
+	return NewPopulation(o.InputNumber, o.HiddenNumber, o.OutputNumber, o.GenomeNumber, o.FitnessThreshold, o.Options)
+}
+
+// Config returns the configuration of the population
+func (o *Population) Config() *Config {
+	return &Config{
+		InputNumber:      o.inputNumber,
+		HiddenNumber:     o.hiddenNumber,
+		OutputNumber:     o.outputNumber,
+		GenomeNumber:     o.genomeNumber,
+		FitnessThreshold: o.fitnessThreshold,
+		Options:          o.Options,
+	}
+}
+
+// Save writes the configuration as JSON
+func (o *Config) Save(w io.Writer) error {
+	bs, err := json.MarshalIndent(o, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(bs, '\n'))
+	return err
+}
+
+// SaveFile ...
+func (o *Config) SaveFile(file string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err := o.Save(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// LoadConfig reads a configuration written by Config.Save, missing options
+// keep the values of DefaultOptions
+func LoadConfig(r io.Reader) (*Config, error) {
+	o := &Config{Options: DefaultOptions()}
+	if err := json.NewDecoder(r).Decode(o); err != nil {
+		return nil, err
+	}
+	if o.Options == nil {
+		o.Options = DefaultOptions()
+	}
+	return o, o.Validate()
+}
+
+// LoadConfigFile ...
+func LoadConfigFile(file string) (*Config, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadConfig(f)
+}
+
+// LoadNEATConfig reads the config-feedforward format of neat-python. Options
+// without a neat-python counterpart keep the values of DefaultOptions, except
+// MaxNode which is math.MaxInt32 since neat-python does not limit hidden
+// nodes, MaxDistance which is 0 so that every offspring may mutate its
+// structure and SingleStructuralMutation which defaults to false as in
+// neat-python. Unknown keys are ignored. Activation functions are mapped to
+// the closest one of neatgo: sigmoid to LOGISTIC, gauss to GAUSSIAN, abs to
+// ABSOLUTE, the others by name, so that functions missing in neatgo like sin
+// fail validation.
+// compatibility_disjoint_coefficient sets both CompatibilityExcess and
+// CompatibilityDisjoint, enabled_mutate_rate sets ToggleEnable and
+// aggregation_default must be sum, new nodes always sum.
+func LoadNEATConfig(r io.Reader) (*Config, error) {
+	sections, err := parseINI(r)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &Config{Options: DefaultOptions()}
+	options := o.Options
+	options.MaxNode = math.MaxInt32
+	options.MaxDistance = 0
+	options.SingleStructuralMutation = false
+
+	initTypes := map[string]bool{}
+	var errs []error
+	float := func(v string, p *float64) {
+		f, err := strconv.ParseFloat(v, 64)
+		errs = append(errs, err)
+		*p = f
+	}
+	integer := func(v string, p *int) {
+		i, err := strconv.Atoi(v)
+		errs = append(errs, err)
+		*p = i
+	}
+	boolean := func(v string, p *bool) {
+		b, err := strconv.ParseBool(v)
+		errs = append(errs, err)
+		*p = b
+	}
+
+	for k, v := range sections["NEAT"] {
+		switch k {
+		case "fitness_criterion":
+			if v != "max" {
+				return nil, fmt.Errorf("neatgo: fitness_criterion %q not supported", v)
+			}
+		case "fitness_threshold":
+			float(v, &o.FitnessThreshold)
+		case "pop_size":
+			integer(v, &o.GenomeNumber)
+		case "reset_on_extinction":
+			boolean(v, &options.ResetOnExtinction)
+		}
+	}
+
+	for k, v := range sections["DefaultGenome"] {
+		switch k {
+		case "num_inputs":
+			integer(v, &o.InputNumber)
+		case "num_hidden":
+			integer(v, &o.HiddenNumber)
+		case "num_outputs":
+			integer(v, &o.OutputNumber)
+		case "feed_forward":
+			feedForward := true
+			boolean(v, &feedForward)
+			options.Recurrent = !feedForward
+		case "initial_connection":
+			options.AllConnection = strings.HasPrefix(v, "full")
+		case "single_structural_mutation":
+			boolean(v, &options.SingleStructuralMutation)
+
+		case "activation_default":
+			options.HiddenActivation = neatActivation(v)
+			options.OutputActivation = options.HiddenActivation
+		case "activation_mutate_rate":
+			float(v, &options.ActivationMutateRate)
+		case "activation_options":
+			options.Activations = nil
+			for _, name := range strings.Fields(v) {
+				options.Activations = append(options.Activations, neatActivation(name))
+			}
+		case "aggregation_default":
+			if v != "sum" {
+				return nil, fmt.Errorf("neatgo: aggregation_default %q not supported", v)
+			}
+		case "aggregation_mutate_rate":
+			float(v, &options.AggregationMutateRate)
+		case "aggregation_options":
+			options.Aggregations = nil
+			for _, name := range strings.Fields(v) {
+				options.Aggregations = append(options.Aggregations, strings.ToUpper(name))
+			}
+
+		case "weight_init_type", "bias_init_type", "response_init_type":
+			initTypes[v] = true
+		case "weight_init_mean":
+			float(v, &options.WeightInitMean)
+		case "weight_init_stdev":
+			float(v, &options.WeightInitStdev)
+		case "weight_mutate_rate":
+			float(v, &options.MutateWeight)
+		case "weight_mutate_power":
+			float(v, &options.WeightMutatePower)
+		case "weight_replace_rate":
+			float(v, &options.WeightReplaceRate)
+		case "weight_min_value":
+			float(v, &options.WeightMinValue)
+		case "weight_max_value":
+			float(v, &options.WeightMaxValue)
+
+		case "bias_init_mean":
+			float(v, &options.BiasInitMean)
+		case "bias_init_stdev":
+			float(v, &options.BiasInitStdev)
+		case "bias_mutate_rate":
+			float(v, &options.BiasMutateRate)
+		case "bias_mutate_power":
+			float(v, &options.BiasMutatePower)
+		case "bias_replace_rate":
+			float(v, &options.BiasReplaceRate)
+		case "bias_min_value":
+			float(v, &options.BiasMinValue)
+		case "bias_max_value":
+			float(v, &options.BiasMaxValue)
+
+		case "response_init_mean":
+			float(v, &options.ResponseInitMean)
+		case "response_init_stdev":
+			float(v, &options.ResponseInitStdev)
+		case "response_mutate_rate":
+			float(v, &options.ResponseMutateRate)
+		case "response_mutate_power":
+			float(v, &options.ResponseMutatePower)
+		case "response_replace_rate":
+			float(v, &options.ResponseReplaceRate)
+		case "response_min_value":
+			float(v, &options.ResponseMinValue)
+		case "response_max_value":
+			float(v, &options.ResponseMaxValue)
+
+		case "compatibility_disjoint_coefficient":
+			float(v, &options.CompatibilityDisjoint)
+			options.CompatibilityExcess = options.CompatibilityDisjoint
+		case "compatibility_weight_coefficient":
+			float(v, &options.CompatibilityWeight)
+		case "conn_add_prob":
+			float(v, &options.AddConnection)
+		case "conn_delete_prob":
+			float(v, &options.DeleteConnection)
+		case "node_add_prob":
+			float(v, &options.AddNode)
+		case "node_delete_prob":
+			float(v, &options.DeleteNode)
+		case "enabled_mutate_rate":
+			float(v, &options.ToggleEnable)
+		}
+	}
+
+	if len(initTypes) > 1 {
+		return nil, fmt.Errorf("neatgo: weight_init_type, bias_init_type and response_init_type must be equal")
+	}
+	for t := range initTypes {
+		options.InitType = t
+	}
+
+	for k, v := range sections["DefaultSpeciesSet"] {
+		if k == "compatibility_threshold" {
+			float(v, &options.CompatibilityThreshold)
+		}
+	}
+
+	for k, v := range sections["DefaultStagnation"] {
+		switch k {
+		case "max_stagnation":
+			integer(v, &options.MaxStagnation)
+		case "species_elitism":
+			integer(v, &options.SpeciesElitism)
+		}
+	}
+
+	for k, v := range sections["DefaultReproduction"] {
+		switch k {
+		case "elitism":
+			integer(v, &options.Elitism)
+		case "survival_threshold":
+			float(v, &options.SurvivalThreshold)
+		}
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("neatgo: %v", err)
+		}
+	}
+	return o, o.Validate()
+}
+
+// LoadNEATConfigFile ...
+func LoadNEATConfigFile(file string) (*Config, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadNEATConfig(f)
+}
+
+// neatActivation maps a neat-python activation function name
+func neatActivation(name string) string {
+	switch name {
+	case "sigmoid":
+		return "LOGISTIC"
+	case "gauss":
+		return "GAUSSIAN"
+	case "abs":
+		return "ABSOLUTE"
+	}
+	return strings.ToUpper(name)
+}
+
+// parseINI reads sections of key = value lines, keys are lower case. Lines
+// starting with # or ; are comments, indented lines continue the value.
+func parseINI(r io.Reader) (map[string]map[string]string, error) {
+	sections := map[string]map[string]string{}
+	var section map[string]string
+	key := ""
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+		switch {
+		case line == "" || line[0] == '#' || line[0] == ';':
+			continue
+		case line[0] == '[' && line[len(line)-1] == ']':
+			section = map[string]string{}
+			sections[strings.TrimSpace(line[1:len(line)-1])] = section
+			key = ""
+			continue
+		case raw[0] == ' ' || raw[0] == '\t':
+			if key != "" {
+				section[key] += " " + line
+				continue
+			}
+		}
+
+		i := strings.IndexAny(line, "=:")
+		if section == nil || i < 0 {
+			return nil, fmt.Errorf("neatgo: config line %d: %q", n, raw)
+		}
+		key = strings.ToLower(strings.TrimSpace(line[:i]))
+		section[key] = strings.TrimSpace(line[i+1:])
+	}
+	return sections, scanner.Err()
+}
@@ -0,0 +1,130 @@
+package neatgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// ErrEvaluationTimeout ...
+var ErrEvaluationTimeout = errors.New("neatgo: evaluation timeout")
+
+// ParallelEvaluator adapts a per genome fitness function to FitnessFunction,
+// evaluating the genomes on a pool of workers. A genome whose evaluation
+// panics, times out or is canceled gets FailedFitness. Without Context the
+// evaluation stops with the context of Population.RunContext.
+type ParallelEvaluator struct {
+	Workers       int             // defaults to runtime.NumCPU()
+	Timeout       time.Duration   // per genome, 0 means no timeout
+	Context       context.Context // stops the evaluation when done
+	FailedFitness float64
+	Evaluate      func(genome *Genome) float64
+
+	mu     sync.Mutex
+	errors []error
+}
+
+// NewParallelEvaluator ...
+func NewParallelEvaluator(workers int, evaluate func(genome *Genome) float64) *ParallelEvaluator {
+	return &ParallelEvaluator{
+		Workers:  workers,
+		Evaluate: evaluate,
+	}
+}
+
+// FitnessFunction evaluates all genomes, pass it to Population.Run
+func (o *ParallelEvaluator) FitnessFunction(genomes []*Genome, generation int, population *Population) {
+	o.mu.Lock()
+	o.errors = nil
+	o.mu.Unlock()
+
+	ctx := o.Context
+	if ctx == nil && population != nil {
+		ctx = population.Context()
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	workers := o.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	jobs := make(chan *Genome)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for genome := range jobs {
+				fitness, err := o.evaluate(ctx, genome)
+				if err != nil {
+					fitness = o.FailedFitness
+					o.mu.Lock()
+					o.errors = append(o.errors, err)
+					o.mu.Unlock()
+				}
+				genome.Fitness = fitness
+			}
+		}()
+	}
+
+	for i, genome := range genomes {
+		if ctx.Err() != nil {
+			for _, g := range genomes[i:] {
+				g.Fitness = o.FailedFitness
+			}
+			o.mu.Lock()
+			o.errors = append(o.errors, ctx.Err())
+			o.mu.Unlock()
+			break
+		}
+		jobs <- genome
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// Errors returns the failures of the last FitnessFunction call
+func (o *ParallelEvaluator) Errors() []error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]error{}, o.errors...)
+}
+
+// evaluate runs Evaluate for one genome. On timeout or cancellation the
+// evaluation goroutine is abandoned and its result discarded.
+func (o *ParallelEvaluator) evaluate(ctx context.Context, genome *Genome) (float64, error) {
+	type result struct {
+		fitness float64
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- result{err: fmt.Errorf("neatgo: evaluation panic: %v", r)}
+			}
+		}()
+		done <- result{fitness: o.Evaluate(genome)}
+	}()
+
+	var timeout <-chan time.Time
+	if o.Timeout > 0 {
+		timer := time.NewTimer(o.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case r := <-done:
+		return r.fitness, r.err
+	case <-timeout:
+		return 0, ErrEvaluationTimeout
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
@@ -2,7 +2,14 @@ package neatgo
 
 import (
 	"encoding/json"
+	"errors"
 	"math"
+	"sort"
+)
+
+var (
+	errMaxNode = errors.New("neatgo: MaxNode hidden nodes reached")
+	errNoSplit = errors.New("neatgo: no enabled connection to split")
 )
 
 // Genome ...
@@ -12,6 +19,8 @@ type Genome struct {
 	Connections []*Connection
 	NextNodeID  int
 	Fitness     float64
+
+	AdjustedFitness float64 `json:"-"`
 }
 
 // NewGenome ...
@@ -24,32 +33,36 @@ func NewGenome(population *Population) (*Genome, error) {
 
 func (o *Genome) init() {
 	for i := 0; i < o.Population.inputNumber; i++ {
-		o.Nodes[o.NextNodeID] = &Node{Index: o.NextNodeID, Type: NodeTypeInput, Value: NeatRandom(-1, 1)}
+		node := NewNode(o.NextNodeID, NodeTypeInput)
+		node.Value = o.Population.random(-1, 1)
+		o.Nodes[o.NextNodeID] = node
 		o.NextNodeID++
 	}
 	for j := 0; j < o.Population.outputNumber; j++ {
-		o.Nodes[o.NextNodeID] = &Node{Index: o.NextNodeID, Type: NodeTypeOutput, Value: 0, Activate: "LOGISTIC"}
+		node := NewNode(o.NextNodeID, NodeTypeOutput)
+		node.Activate = o.Population.Options.outputActivation()
+		o.Nodes[o.NextNodeID] = node
+		o.initNode(node)
 
 		if o.Population.Options.AllConnection {
 			for i := 0; i < o.Population.inputNumber; i++ {
 				o.Connections = append(o.Connections, &Connection{
 					In:         o.Nodes[i].Index,
 					Out:        o.NextNodeID,
-					Weight:     NeatRandom(-1, 1),
+					Weight:     o.Population.Options.weightGene().init(o.Population),
 					Enabled:    true,
-					Innovation: o.Population.nextInnovationID,
+					Innovation: o.Population.linkInnovation(o.Nodes[i].Index, o.NextNodeID),
 				})
-				o.Population.nextInnovationID++
 			}
 		} else {
+			in := o.Population.randIntn(0, o.Population.inputNumber-1)
 			o.Connections = append(o.Connections, &Connection{
-				In:         RandIntn(0, o.Population.inputNumber-1),
+				In:         in,
 				Out:        o.NextNodeID,
-				Weight:     NeatRandom(-1, 1),
+				Weight:     o.Population.Options.weightGene().init(o.Population),
 				Enabled:    true,
-				Innovation: o.Population.nextInnovationID,
+				Innovation: o.Population.linkInnovation(in, o.NextNodeID),
 			})
-			o.Population.nextInnovationID++
 		}
 
 		o.NextNodeID++
@@ -58,126 +71,368 @@ func (o *Genome) init() {
 		o.addNode()
 	}
 }
-func (o *Genome) nextGeneration(n, dis int) {
-	o.mutateWeight(n, dis)
-	if dis < o.Population.Options.MaxDistance {
+
+// initNode draws the bias and response of a new node
+func (o *Genome) initNode(node *Node) {
+	node.Bias = o.Population.Options.biasGene().init(o.Population)
+	node.Response = o.Population.Options.responseGene().init(o.Population)
+}
+
+// nextGeneration mutates an offspring: the numeric genes and functions, the
+// structure once dis reaches MaxDistance, then the mutators of the population
+func (o *Genome) nextGeneration(dis int) {
+	o.mutateWeight()
+	o.mutateActivation()
+	o.mutateBias()
+	o.mutateResponse()
+	o.mutateAggregation()
+	if o.Population.random(0, 1) < o.Population.Options.ToggleEnable {
+		o.toggleEnable()
+	}
+	if dis >= o.Population.Options.MaxDistance {
+		o.mutateStructure()
+	}
+	for _, m := range o.Population.mutators {
+		m.Mutate(o, o.Population.rand)
+	}
+}
+
+// mutateStructure applies each structural mutation with its probability, or
+// at most one of them with SingleStructuralMutation
+func (o *Genome) mutateStructure() {
+	options := o.Population.Options
+	if !options.SingleStructuralMutation {
+		if o.Population.random(0, 1) < options.AddNode {
+			o.addNode()
+		}
+		if o.Population.random(0, 1) < options.AddConnection {
+			o.addConnection()
+		}
+		if o.Population.random(0, 1) < options.DeleteNode {
+			o.deleteNode()
+		}
+		if o.Population.random(0, 1) < options.DeleteConnection {
+			o.deleteConnection()
+		}
 		return
 	}
 
-	div := math.Max(1, o.Population.Options.AddNode+o.Population.Options.AddConnection)
-	r, r1, r2 := NeatRandom(0, 1), o.Population.Options.AddNode, o.Population.Options.AddConnection
+	div := math.Max(1, options.AddNode+options.AddConnection+options.DeleteNode+options.DeleteConnection)
+	r, r1, r2, r3, r4 := o.Population.random(0, 1), options.AddNode, options.AddConnection, options.DeleteNode, options.DeleteConnection
 	if r < r1/div {
 		o.addNode()
 	} else if r < (r1+r2)/div {
 		o.addConnection()
+	} else if r < (r1+r2+r3)/div {
+		o.deleteNode()
+	} else if r < (r1+r2+r3+r4)/div {
+		o.deleteConnection()
+	}
+}
+func (o *Genome) mutateWeight() {
+	gene := o.Population.Options.weightGene()
+	for _, c := range o.Connections {
+		c.Weight = gene.mutate(o.Population, c.Weight)
+	}
+}
+
+// mutateBias perturbs the bias of hidden and output nodes
+func (o *Genome) mutateBias() {
+	gene := o.Population.Options.biasGene()
+	for _, k := range o.nodeIDs() {
+		if o.Nodes[k].Type == NodeTypeInput {
+			continue
+		}
+		o.Nodes[k].Bias = gene.mutate(o.Population, o.Nodes[k].Bias)
+	}
+}
+
+// mutateResponse perturbs the response of hidden and output nodes
+func (o *Genome) mutateResponse() {
+	gene := o.Population.Options.responseGene()
+	for _, k := range o.nodeIDs() {
+		if o.Nodes[k].Type == NodeTypeInput {
+			continue
+		}
+		o.Nodes[k].Response = gene.mutate(o.Population, o.Nodes[k].Response)
+	}
+}
+
+// mutateAggregation replaces the aggregation function of hidden and output
+// nodes with AggregationMutateRate
+func (o *Genome) mutateAggregation() {
+	if o.Population.Options.AggregationMutateRate <= 0 {
+		return
+	}
+	for _, k := range o.nodeIDs() {
+		if o.Nodes[k].Type == NodeTypeInput {
+			continue
+		}
+		if o.Population.random(0, 1) < o.Population.Options.AggregationMutateRate {
+			o.Nodes[k].Aggregation = o.Population.randAggregateFunc()
+		}
 	}
 }
-func (o *Genome) mutateWeight(n, dis int) {
-	r := 0.0
-	for i := 0; i < len(o.Connections); i++ {
-		r = NeatRandom(0, 1)
-		if r < 0.01 {
-			o.Connections[i].Weight = NeatRandom(-1, 1)
-		} else if r < o.Population.Options.MutateWeight {
-			o.Connections[i].Weight += NeatRandom(-1, 1) * math.Min(float64(n+1), 10)
+
+// mutateActivation replaces the activation function of hidden and output
+// nodes with ActivationMutateRate
+func (o *Genome) mutateActivation() {
+	if o.Population.Options.ActivationMutateRate <= 0 {
+		return
+	}
+	for _, k := range o.nodeIDs() {
+		if o.Nodes[k].Type == NodeTypeInput {
+			continue
+		}
+		if o.Population.random(0, 1) < o.Population.Options.ActivationMutateRate {
+			o.Nodes[k].Activate = o.Population.randActivateFunc()
 		}
 	}
 }
+
+// crossover builds a new child aligned on innovation numbers: matching genes
+// are inherited randomly, disjoint and excess genes from the fitter parent (or
+// from both when equal). Neither parent is modified.
 func (o *Genome) crossover(b *Genome) *Genome {
-	for m := range o.Connections {
-		for n := range b.Connections {
-			if b.Connections[n].Innovation != o.Connections[m].Innovation {
-				continue
-			}
+	fit, other := o, b
+	if b.Fitness > o.Fitness {
+		fit, other = b, o
+	}
+	equal := o.Fitness == b.Fitness
 
-			// o.Connections[m].Enabled = true
-			// b.Connections[n].Enabled = true
+	child, _ := NewGenome(o.Population)
+	child.NextNodeID = fit.NextNodeID
+	if equal && other.NextNodeID > child.NextNodeID {
+		child.NextNodeID = other.NextNodeID
+	}
 
-			if !o.Connections[m].Enabled || !b.Connections[n].Enabled {
-				if NeatRandom(0, 1) < 0.75 {
-					o.Connections[m].Enabled = false
-					b.Connections[n].Enabled = false
-				}
+	genes := make(map[int64]*Connection, len(other.Connections))
+	for _, c := range other.Connections {
+		genes[c.Innovation] = c
+	}
+	links := make(map[[2]int]bool)
+	disabled := []*Connection{}
+	for _, c := range fit.sortedConnections() {
+		g := c.Clone()
+		if m, ok := genes[c.Innovation]; ok {
+			if o.Population.random(0, 1) < 0.5 {
+				g = m.Clone()
+			}
+			g.Enabled = c.Enabled && m.Enabled
+			delete(genes, c.Innovation)
+		}
+		if !g.Enabled {
+			disabled = append(disabled, g)
+		}
+		links[[2]int{g.In, g.Out}] = true
+		child.Connections = append(child.Connections, g)
+	}
+	if equal {
+		for _, c := range other.sortedConnections() {
+			if _, ok := genes[c.Innovation]; !ok || links[[2]int{c.In, c.Out}] {
+				continue
+			}
+			g := c.Clone()
+			if g.Enabled && !o.Population.Options.Recurrent && child.createsCycle(g.In, g.Out) {
+				g.Enabled = false
+			}
+			if !g.Enabled {
+				disabled = append(disabled, g)
 			}
+			links[[2]int{c.In, c.Out}] = true
+			child.Connections = append(child.Connections, g)
+		}
+	}
+
+	// genes disabled in either parent are enabled again with ReenableRate
+	for _, g := range disabled {
+		if o.Population.random(0, 1) < o.Population.Options.ReenableRate {
+			g.Enabled = o.Population.Options.Recurrent || !child.createsCycle(g.In, g.Out)
+		}
+	}
 
-			if NeatRandom(0, 1) < 0.5 {
-				x := b.Connections[n].Weight
-				b.Connections[n].Weight = o.Connections[m].Weight
-				o.Connections[m].Weight = x
+	for _, k := range fit.nodeIDs() {
+		v := fit.Nodes[k]
+		if m, ok := other.Nodes[k]; ok && o.Population.random(0, 1) < 0.5 {
+			v = m
+		}
+		child.Nodes[k] = v.Clone()
+	}
+	if equal {
+		for k, v := range other.Nodes {
+			if _, ok := child.Nodes[k]; !ok {
+				child.Nodes[k] = v.Clone()
 			}
 		}
 	}
 
-	return o
+	return child
 }
+
+// addConnection links a random unconnected pair of nodes, trying up to
+// AddConnectionTries pairs. Without Recurrent a pair closing a cycle is invalid.
 func (o *Genome) addConnection() {
-	for in := range o.Nodes {
-		if o.Nodes[in].Type == NodeTypeOutput {
-			continue
+	ins, outs := []int{}, []int{}
+	for _, k := range o.nodeIDs() {
+		if o.Nodes[k].Type != NodeTypeOutput || o.Population.Options.Recurrent {
+			ins = append(ins, k)
 		}
-		for out := range o.Nodes {
-			if o.Nodes[out].Type == NodeTypeInput || out <= in {
-				continue
-			}
+		if o.Nodes[k].Type != NodeTypeInput {
+			outs = append(outs, k)
+		}
+	}
+	if len(ins) == 0 || len(outs) == 0 {
+		return
+	}
 
-			found := false
-			for _, c := range o.Connections {
-				if c.In == in && c.Out == out {
-					found = true
-					break
-				}
-			}
-			if found {
-				continue
-			}
+	links := make(map[[2]int]bool, len(o.Connections))
+	for _, c := range o.Connections {
+		links[[2]int{c.In, c.Out}] = true
+	}
 
-			o.Connections = append(o.Connections, &Connection{
-				In:         in,
-				Out:        out,
-				Weight:     NeatRandom(-1, 1),
-				Enabled:    true,
-				Innovation: o.Population.nextInnovationID,
-			})
-			o.Population.nextInnovationID++
-			return
+	for try := 0; try < o.Population.Options.addConnectionTries(); try++ {
+		in, out := ins[o.Population.randIntn(0, len(ins)-1)], outs[o.Population.randIntn(0, len(outs)-1)]
+		if links[[2]int{in, out}] || (!o.Population.Options.Recurrent && o.createsCycle(in, out)) {
+			continue
 		}
+
+		o.Connections = append(o.Connections, &Connection{
+			In:         in,
+			Out:        out,
+			Weight:     o.Population.Options.weightGene().init(o.Population),
+			Enabled:    true,
+			Innovation: o.Population.linkInnovation(in, out),
+		})
+		return
 	}
 }
-func (o *Genome) addNode() {
-	if len(o.Nodes) > o.Population.Options.MaxNode {
-		return
+
+// addNode splits a random enabled connection with a new hidden node, unless
+// the genome has MaxNode hidden nodes
+func (o *Genome) addNode() error {
+	if o.hiddenNumber() >= o.Population.Options.MaxNode {
+		return errMaxNode
 	}
-	outs := []*Connection{}
-	for a := range o.Connections {
-		if o.Nodes[o.Connections[a].Out].Type == NodeTypeOutput {
-			outs = append(outs, o.Connections[a])
+	enabled := []*Connection{}
+	for _, c := range o.Connections {
+		if c.Enabled {
+			enabled = append(enabled, c)
 		}
 	}
+	if len(enabled) == 0 {
+		return errNoSplit
+	}
 
-	o.Nodes[o.NextNodeID] = &Node{Index: o.NextNodeID, Type: NodeTypeHidden, Value: 0, Activate: "LOGISTIC"}
-	// o.Nodes[o.NextNodeID] = &Node{Index: o.NextNodeID, Type: NodeTypeHidden, Value: 0, Activate: randActivateFunc()}
+	c := enabled[o.Population.randIntn(0, len(enabled)-1)]
+	id := o.Population.splitNode(o, c)
+	node := NewNode(id, NodeTypeHidden)
+	node.Activate = o.Population.Options.hiddenActivation()
+	o.Nodes[id] = node
+	o.initNode(node)
 
-	c := RandIntn(0, len(outs)-1)
-	outs[c].Enabled = false
+	c.Enabled = false
 	o.Connections = append(o.Connections, &Connection{
-		In:         outs[c].In,
-		Out:        o.NextNodeID,
-		Weight:     NeatRandom(-1, 1),
+		In:         c.In,
+		Out:        id,
+		Weight:     o.Population.Options.weightGene().init(o.Population),
 		Enabled:    true,
-		Innovation: o.Population.nextInnovationID,
+		Innovation: o.Population.linkInnovation(c.In, id),
 	})
-	o.Population.nextInnovationID++
 	o.Connections = append(o.Connections, &Connection{
-		In:  o.NextNodeID,
-		Out: outs[c].Out,
+		In:  id,
+		Out: c.Out,
 		// Weight:     NeatRandom(-1, 1),
-		Weight:     outs[c].Weight,
+		Weight:     c.Weight,
 		Enabled:    true,
-		Innovation: o.Population.nextInnovationID,
+		Innovation: o.Population.linkInnovation(id, c.Out),
 	})
-	o.Population.nextInnovationID++
 
-	o.NextNodeID++
+	if id >= o.NextNodeID {
+		o.NextNodeID = id + 1
+	}
+	return nil
+}
+
+// hiddenNumber returns the number of hidden nodes
+func (o *Genome) hiddenNumber() int {
+	n := 0
+	for _, node := range o.Nodes {
+		if node.Type == NodeTypeHidden {
+			n++
+		}
+	}
+	return n
+}
+
+// toggleEnable disables a random enabled connection or enables a disabled
+// one, unless it would close a cycle in feed-forward mode
+func (o *Genome) toggleEnable() {
+	if len(o.Connections) == 0 {
+		return
+	}
+	c := o.Connections[o.Population.randIntn(0, len(o.Connections)-1)]
+	if c.Enabled {
+		c.Enabled = false
+	} else if o.Population.Options.Recurrent || !o.createsCycle(c.In, c.Out) {
+		c.Enabled = true
+	}
+}
+
+// createsCycle reports whether an enabled connection in -> out would close a
+// cycle, that is whether out already reaches in
+func (o *Genome) createsCycle(in, out int) bool {
+	if in == out {
+		return true
+	}
+	visited := map[int]bool{out: true}
+	queue := []int{out}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, c := range o.Connections {
+			if !c.Enabled || c.In != n || visited[c.Out] {
+				continue
+			}
+			if c.Out == in {
+				return true
+			}
+			visited[c.Out] = true
+			queue = append(queue, c.Out)
+		}
+	}
+	return false
+}
+
+// deleteNode removes a random hidden node with all its connections
+func (o *Genome) deleteNode() {
+	hidden := []int{}
+	for _, k := range o.nodeIDs() {
+		if o.Nodes[k].Type == NodeTypeHidden {
+			hidden = append(hidden, k)
+		}
+	}
+	if len(hidden) == 0 {
+		return
+	}
+
+	id := hidden[o.Population.randIntn(0, len(hidden)-1)]
+	delete(o.Nodes, id)
+	connections := []*Connection{}
+	for _, c := range o.Connections {
+		if c.In != id && c.Out != id {
+			connections = append(connections, c)
+		}
+	}
+	o.Connections = connections
+}
+
+// deleteConnection removes a random connection
+func (o *Genome) deleteConnection() {
+	if len(o.Connections) == 0 {
+		return
+	}
+	i := o.Population.randIntn(0, len(o.Connections)-1)
+	o.Connections = append(o.Connections[:i:i], o.Connections[i+1:]...)
 }
 
 // ToJSON ...
@@ -190,33 +445,30 @@ func (o *Genome) ToJSON() string {
 func (o *Genome) LoadJSON(js string) error {
 	return json.Unmarshal([]byte(js), o)
 }
+func (o *Genome) nodeIDs() []int {
+	ids := make([]int, 0, len(o.Nodes))
+	for k := range o.Nodes {
+		ids = append(ids, k)
+	}
+	sort.Ints(ids)
+	return ids
+}
 func (o *Genome) clone() *Genome {
 	n, _ := NewGenome(o.Population)
 	n.NextNodeID = o.NextNodeID
 	n.Fitness = o.Fitness
 	for k, v := range o.Nodes {
-		n.Nodes[k] = &Node{Index: v.Index, Type: v.Type, Value: v.Value, Activate: v.Activate}
+		n.Nodes[k] = v.Clone()
 	}
 	for _, v := range o.Connections {
-		n.Connections = append(n.Connections, &Connection{
-			In:         v.In,
-			Out:        v.Out,
-			Weight:     v.Weight,
-			Enabled:    v.Enabled,
-			Innovation: v.Innovation,
-		})
+		n.Connections = append(n.Connections, v.Clone())
 	}
 	return n
 }
 
 // GetActiveNodeNumber ...
 func (o *Genome) GetActiveNodeNumber() int {
-	return o.NextNodeID
-	// nn := 0
-	// for range o.Nodes {
-	// 	nn++
-	// }
-	// return nn
+	return len(o.Nodes)
 }
 
 // GetActiveConnectionNumber ...
@@ -243,7 +495,10 @@ func (s Genomes) Less(i, j int) bool {
 		if aid == bid {
 			c1, c2 := s[i].GetActiveConnectionNumber(), s[j].GetActiveConnectionNumber()
 			if c1 == c2 {
-				return NeatRandom(0, 1) < 0.5
+				if s[i].Population == nil {
+					return false
+				}
+				return s[i].Population.random(0, 1) < 0.5
 			}
 			return c1 < c2
 		}
@@ -0,0 +1,62 @@
+package neatgo
+
+// innovationTracker hands out historical markings, so identical structural
+// mutations within a generation share innovation numbers and node IDs
+type innovationTracker struct {
+	links  map[[2]int]int64 // (in, out) => innovation
+	splits map[int64]int    // split connection innovation => node ID
+}
+
+func newInnovationTracker() *innovationTracker {
+	return &innovationTracker{
+		links:  make(map[[2]int]int64),
+		splits: make(map[int64]int),
+	}
+}
+
+func (o *innovationTracker) reset() {
+	o.links = make(map[[2]int]int64)
+	o.splits = make(map[int64]int)
+}
+
+// linkInnovation returns the innovation number of the connection in -> out
+func (o *Population) linkInnovation(in, out int) int64 {
+	key := [2]int{in, out}
+	if id, ok := o.innovations.links[key]; ok {
+		return id
+	}
+	id := o.nextInnovationID
+	o.nextInnovationID++
+	o.innovations.links[key] = id
+	return id
+}
+
+// splitNode returns the ID of the node inserted into connection c of genome,
+// shared with every genome that splits the same connection
+func (o *Population) splitNode(genome *Genome, c *Connection) int {
+	if id, ok := o.innovations.splits[c.Innovation]; ok {
+		if _, exists := genome.Nodes[id]; !exists {
+			return id
+		}
+	}
+	id := o.nextNodeID
+	o.nextNodeID++
+	if _, ok := o.innovations.splits[c.Innovation]; !ok {
+		o.innovations.splits[c.Innovation] = id
+	}
+	return id
+}
+
+// syncInnovations moves the counters past every marking used by genome
+func (o *Population) syncInnovations(genome *Genome) {
+	for _, c := range genome.Connections {
+		if c.Innovation >= o.nextInnovationID {
+			o.nextInnovationID = c.Innovation + 1
+		}
+	}
+	for k := range genome.Nodes {
+		if k >= o.nextNodeID {
+			o.nextNodeID = k + 1
+		}
+	}
+}
@@ -9,7 +9,6 @@ import (
 	"neatgo"
 	"neatgo/mnist"
 	"runtime"
-	"sync"
 )
 
 var (
@@ -28,14 +27,11 @@ func main() {
 	}
 
 	jsonFile := "neatgo_mnist.json"
-	pop, _ := neatgo.NewPopulation(28/2*28/2, 0, 10, *g, 0.99, &neatgo.Options{
-		KeepWinner:    0,
-		AddNode:       0.2,
-		AddConnection: 0.2,
-		MutateWeight:  0.2,
-		MaxDistance:   2,
-		AllConnection: true,
-	})
+	checkpointFile := "neatgo_mnist.checkpoint"
+	options := neatgo.DefaultOptions()
+	options.SurvivalThreshold = 0.2
+	pop, _ := neatgo.NewPopulation(28/2*28/2, 0, 10, *g, 0.99, options)
+	pop.Selector = neatgo.TournamentSelector{Size: 3}
 
 	if *t {
 		resultChk(pop, jsonFile)
@@ -86,51 +82,64 @@ func main() {
 		}
 	}
 
-	maxFitness := 0.0
-	fitnessFunction := func(genomes []*neatgo.Genome, generation int, population *neatgo.Population) {
-		fmt.Printf("generation:%d nodes:%d/%d connections:%d/%d fitness:%.3f%%\r", generation, genomes[0].GetActiveNodeNumber(), genomes[0].NextNodeID, genomes[0].GetActiveConnectionNumber(), len(genomes[0].Connections), genomes[0].Fitness*100)
-		if generation%100 == 0 {
-			fmt.Println()
-		}
-		// save
-		if genomes[0].Fitness > maxFitness+0.01 {
-			if maxFitness != 0 {
-				ioutil.WriteFile(jsonFile, []byte(genomes[0].ToJSON()), 0644)
+	evaluator := neatgo.NewParallelEvaluator(0, func(genome *neatgo.Genome) float64 {
+		right := 0
+		network := neatgo.Compile(genome)
+		for _, v := range dataTrainSet {
+			if outputChk(network, v[0], int(v[1][0])) {
+				right++
 			}
-			maxFitness = genomes[0].Fitness
-		}
-
-		var wg sync.WaitGroup
-
-		wg.Add(len(genomes))
-		for _, genome := range genomes {
-			go func(genome *neatgo.Genome) {
-				right := 0
-				for _, v := range dataTrainSet {
-					if outputChk(genome, v[0], int(v[1][0])) {
-						right++
-					}
-				}
-				genome.Fitness = float64(right) / float64(trainCount*10)
-
-				wg.Done()
-			}(genome)
 		}
+		return float64(right) / float64(trainCount*10)
+	})
 
-		wg.Wait()
-	}
+	pop.AddReporter(&progress{file: jsonFile})
 
-	{ // train
+	{ // train, resume from the checkpoint saved on ctrl+c
 		js, _ := ioutil.ReadFile(jsonFile)
-		winner := pop.Run(fitnessFunction, -1, string(js))
+		if err := pop.LoadCheckpointFile(checkpointFile); err == nil {
+			log.Printf("resume from %s", checkpointFile)
+		}
+		winner, reason, err := pop.RunInterruptible(evaluator.FitnessFunction, -1, string(js), checkpointFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if winner == nil {
+			return
+		}
+		if reason == neatgo.StopCanceled {
+			fmt.Println()
+			log.Printf("interrupted, checkpoint saved to %s", checkpointFile)
+		}
 		ioutil.WriteFile(jsonFile, []byte(winner.ToJSON()), 0644)
 		winners := winner.Population.Winners
 		fmt.Printf("nodes:%d connections:%d fitness:%.3f\n", winners[0].GetActiveNodeNumber(), winners[0].GetActiveConnectionNumber(), winners[0].Fitness)
 	}
 }
 
-func outputChk(genome *neatgo.Genome, inputs []float64, want int) bool {
-	outputs := neatgo.FeedForwardNetwork(genome, inputs)
+// progress prints the best genome and saves it whenever it improved by 1%
+type progress struct {
+	neatgo.BaseReporter
+	file       string
+	maxFitness float64
+}
+
+func (o *progress) PostEvaluate(population *neatgo.Population, best *neatgo.Genome) {
+	fmt.Printf("generation:%d species:%d nodes:%d connections:%d/%d fitness:%.3f%%\r", population.Generation(), len(population.Species), best.GetActiveNodeNumber(), best.GetActiveConnectionNumber(), len(best.Connections), best.Fitness*100)
+	if population.Generation()%100 == 0 {
+		fmt.Println()
+	}
+	// save
+	if best.Fitness > o.maxFitness+0.01 {
+		if o.maxFitness != 0 {
+			ioutil.WriteFile(o.file, []byte(best.ToJSON()), 0644)
+		}
+		o.maxFitness = best.Fitness
+	}
+}
+
+func outputChk(network *neatgo.Network, inputs []float64, want int) bool {
+	outputs := network.Activate(inputs)
 	maxV, maxI := 0.0, 0
 	for ok, ov := range outputs {
 		if ov > maxV {
@@ -231,8 +240,9 @@ func resultChk(pop *neatgo.Population, jsonFile string) {
 	}
 	genome.LoadJSON(string(js))
 
+	network := neatgo.Compile(genome)
 	for _, v := range dataCheckSet {
-		if outputChk(genome, v[0], int(v[1][0])) {
+		if outputChk(network, v[0], int(v[1][0])) {
 			right++
 		}
 	}
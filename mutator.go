@@ -0,0 +1,23 @@
+package neatgo
+
+import "math/rand"
+
+// Mutator is a custom mutation, applied to every offspring after the built-in
+// mutations. rnd is the generator of the population, not safe for concurrent
+// use.
+type Mutator interface {
+	Mutate(genome *Genome, rnd *rand.Rand)
+}
+
+// MutatorFunc adapts a function to Mutator
+type MutatorFunc func(genome *Genome, rnd *rand.Rand)
+
+// Mutate ...
+func (o MutatorFunc) Mutate(genome *Genome, rnd *rand.Rand) {
+	o(genome, rnd)
+}
+
+// AddMutator adds a custom mutation
+func (o *Population) AddMutator(mutator Mutator) {
+	o.mutators = append(o.mutators, mutator)
+}
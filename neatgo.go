@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"math"
 	"math/rand"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -12,13 +13,120 @@ import (
 
 // Options ...
 type Options struct {
+	// Winners keeps KeepWinner genomes of previous generations until the best
+	// fitness stagnates for more than MaxDistance generations. It only changes
+	// the genome Run returns, winners do not breed.
 	KeepWinner    int
 	AddNode       float64
 	AddConnection float64
 	MutateWeight  float64
-	MaxDistance   int
-	MaxNode       int
+
+	// apply at most one of AddNode, AddConnection, DeleteNode and
+	// DeleteConnection to an offspring, else each independently
+	SingleStructuralMutation bool
+
+	// numeric genes are initialized by InitType, "gaussian" of mean and
+	// stdev or "uniform" within two stdev of the mean, and perturbed by
+	// MutateType, "gaussian" or "uniform" scaled by the power
+	InitType   string
+	MutateType string
+
+	// connection weights, perturbed with MutateWeight, replaced with
+	// WeightReplaceRate and clamped to [WeightMinValue, WeightMaxValue],
+	// not clamped when both are zero
+	WeightInitMean    float64
+	WeightInitStdev   float64
+	WeightMutatePower float64
+	WeightReplaceRate float64
+	WeightMinValue    float64
+	WeightMaxValue    float64
+
+	// structural mutations removing a hidden node or a connection
+	DeleteNode       float64
+	DeleteConnection float64
+
+	// chance to toggle a random connection, and to enable again a gene
+	// inherited disabled
+	ToggleEnable float64
+	ReenableRate float64
+
+	// activation functions of new hidden and output nodes, LOGISTIC when
+	// empty. ActivationMutateRate is the chance of a node to switch to a
+	// random function of Activations, all functions when empty
+	HiddenActivation     string
+	OutputActivation     string
+	ActivationMutateRate float64
+	Activations          []string
+
+	// bias of hidden and output nodes, mutated like the weights
+	BiasInitMean    float64
+	BiasInitStdev   float64
+	BiasMutateRate  float64
+	BiasMutatePower float64
+	BiasReplaceRate float64
+	BiasMinValue    float64
+	BiasMaxValue    float64
+
+	// response multiplier of the aggregated inputs, mutated like the weights.
+	// AggregationMutateRate is the chance of a node to switch to a random
+	// function of Aggregations, all functions when empty
+	ResponseInitMean      float64
+	ResponseInitStdev     float64
+	ResponseMutateRate    float64
+	ResponseMutatePower   float64
+	ResponseReplaceRate   float64
+	ResponseMinValue      float64
+	ResponseMaxValue      float64
+	AggregationMutateRate float64
+	Aggregations          []string
+
+	MaxDistance   int // generations without improvement before structural mutations
+	MaxNode       int // hidden nodes of a genome
 	AllConnection bool
+
+	// random pairs of nodes tried by the add connection mutation
+	AddConnectionTries int
+
+	// random seed of the population, 0 seeds from the clock. Source replaces
+	// the built-in generator, but then its state is not checkpointed
+	Seed   int64
+	Source rand.Source `json:"-"`
+
+	// save a checkpoint named CheckpointPrefix + generation every
+	// CheckpointInterval generations of Run, 0 disables
+	CheckpointInterval int
+	CheckpointPrefix   string
+
+	// allow recurrent and self connections, evaluated by RecurrentNetwork
+	Recurrent bool
+
+	// keep the innovation registry for the whole run instead of one generation
+	PersistInnovations bool
+
+	// speciation: c1 * excess / N + c2 * disjoint / N + c3 * avg weight difference
+	CompatibilityExcess    float64
+	CompatibilityDisjoint  float64
+	CompatibilityWeight    float64
+	CompatibilityThreshold float64
+
+	// species not improving for MaxStagnation generations are removed, 0
+	// disables, the SpeciesElitism best species are always kept. When all
+	// species are removed the population is reset with ResetOnExtinction,
+	// else Run stops with StopSpeciesExtinct
+	MaxStagnation     int
+	SpeciesElitism    int
+	ResetOnExtinction bool
+
+	// fraction of each species allowed to reproduce, 0 means all
+	SurvivalThreshold float64
+
+	// the Elitism best genomes of every species are copied unmodified into the
+	// next generation, CrossoverRate of the other offspring are produced by
+	// crossover and the rest by mutation only. Winners keeps the WinnerPool
+	// best genomes
+	Elitism       int
+	CrossoverRate float64
+	WinnerPool    int
 }
 
 // DefaultOptions ...
@@ -29,9 +137,141 @@ func DefaultOptions() *Options {
 		AddConnection: 0.2,
 		MutateWeight:  0.2,
 		MaxDistance:   2,
+
+		SingleStructuralMutation: true,
+
+		InitType:   "gaussian",
+		MutateType: "gaussian",
+
+		WeightInitMean:    0,
+		WeightInitStdev:   1,
+		WeightMutatePower: 0.5,
+		WeightReplaceRate: 0.01,
+		WeightMinValue:    -30,
+		WeightMaxValue:    30,
+
+		DeleteNode:       0.05,
+		DeleteConnection: 0.05,
+
+		ToggleEnable: 0.05,
+		ReenableRate: 0.25,
+
+		HiddenActivation: "LOGISTIC",
+		OutputActivation: "LOGISTIC",
+
+		BiasInitStdev:   1,
+		BiasMutateRate:  0.2,
+		BiasMutatePower: 0.5,
+		BiasReplaceRate: 0.01,
+		BiasMinValue:    -30,
+		BiasMaxValue:    30,
+
+		ResponseInitMean:    1,
+		ResponseMutatePower: 0.5,
+		ResponseMinValue:    -30,
+		ResponseMaxValue:    30,
+
 		MaxNode:       10,
 		AllConnection: true,
+
+		AddConnectionTries: 20,
+
+		CheckpointPrefix: "neatgo-checkpoint-",
+
+		CompatibilityExcess:    1.0,
+		CompatibilityDisjoint:  1.0,
+		CompatibilityWeight:    0.4,
+		CompatibilityThreshold: 3.0,
+
+		MaxStagnation:  15,
+		SpeciesElitism: 2,
+
+		SurvivalThreshold: 0.4,
+
+		Elitism:       1,
+		CrossoverRate: 0.8,
+		WinnerPool:    4,
+	}
+}
+
+// setDefaults fills the parameters left zero by Options literals written
+// before they existed. The bias genes stay zero, nodes had no bias before.
+func (o *Options) setDefaults() {
+	defaults := DefaultOptions()
+	if o.CompatibilityExcess == 0 && o.CompatibilityDisjoint == 0 && o.CompatibilityWeight == 0 {
+		o.CompatibilityExcess = defaults.CompatibilityExcess
+		o.CompatibilityDisjoint = defaults.CompatibilityDisjoint
+		o.CompatibilityWeight = defaults.CompatibilityWeight
+	}
+	if o.CompatibilityThreshold == 0 {
+		o.CompatibilityThreshold = defaults.CompatibilityThreshold
+	}
+	if o.CrossoverRate == 0 && o.Elitism == 0 && o.SurvivalThreshold == 0 {
+		o.CrossoverRate = defaults.CrossoverRate
+		o.Elitism = defaults.Elitism
+		o.SurvivalThreshold = defaults.SurvivalThreshold
+	}
+	if o.WinnerPool == 0 {
+		o.WinnerPool = defaults.WinnerPool
+	}
+	if o.WeightInitMean == 0 && o.WeightInitStdev == 0 && o.WeightMutatePower == 0 && o.WeightReplaceRate == 0 && o.WeightMinValue == 0 && o.WeightMaxValue == 0 {
+		o.WeightInitStdev = defaults.WeightInitStdev
+		o.WeightMutatePower = defaults.WeightMutatePower
+		o.WeightReplaceRate = defaults.WeightReplaceRate
+		o.WeightMinValue = defaults.WeightMinValue
+		o.WeightMaxValue = defaults.WeightMaxValue
+	}
+	if o.ResponseInitMean == 0 && o.ResponseInitStdev == 0 && o.ResponseMutateRate == 0 && o.ResponseMutatePower == 0 && o.ResponseReplaceRate == 0 && o.ResponseMinValue == 0 && o.ResponseMaxValue == 0 {
+		o.ResponseInitMean = defaults.ResponseInitMean
+		o.ResponseMutatePower = defaults.ResponseMutatePower
+		o.ResponseMinValue = defaults.ResponseMinValue
+		o.ResponseMaxValue = defaults.ResponseMaxValue
+	}
+}
+
+func (o *Options) weightGene() floatGene {
+	return floatGene{
+		initType: o.InitType, mean: o.WeightInitMean, stdev: o.WeightInitStdev,
+		rate: o.MutateWeight, power: o.WeightMutatePower, replace: o.WeightReplaceRate,
+		mutateType: o.MutateType, min: o.WeightMinValue, max: o.WeightMaxValue,
+	}
+}
+
+func (o *Options) biasGene() floatGene {
+	return floatGene{
+		initType: o.InitType, mean: o.BiasInitMean, stdev: o.BiasInitStdev,
+		rate: o.BiasMutateRate, power: o.BiasMutatePower, replace: o.BiasReplaceRate,
+		mutateType: o.MutateType, min: o.BiasMinValue, max: o.BiasMaxValue,
+	}
+}
+
+func (o *Options) responseGene() floatGene {
+	return floatGene{
+		initType: o.InitType, mean: o.ResponseInitMean, stdev: o.ResponseInitStdev,
+		rate: o.ResponseMutateRate, power: o.ResponseMutatePower, replace: o.ResponseReplaceRate,
+		mutateType: o.MutateType, min: o.ResponseMinValue, max: o.ResponseMaxValue,
+	}
+}
+
+func (o *Options) addConnectionTries() int {
+	if o.AddConnectionTries <= 0 {
+		return 1
+	}
+	return o.AddConnectionTries
+}
+
+func (o *Options) hiddenActivation() string {
+	if o.HiddenActivation == "" {
+		return "LOGISTIC"
 	}
+	return o.HiddenActivation
+}
+
+func (o *Options) outputActivation() string {
+	if o.OutputActivation == "" {
+		return "LOGISTIC"
+	}
+	return o.OutputActivation
 }
 
 // FitnessFunction ...
@@ -66,57 +306,101 @@ var activateFunc = map[string]func(x float64) float64{
 	},
 }
 
-func randActivateFunc() string {
-	ids := []string{}
-	for i := range activateFunc {
-		ids = append(ids, i)
-	}
-	return ids[RandIntn(0, len(ids)-1)]
-}
-
-// FeedForwardNetwork ...
-func FeedForwardNetwork(genome *Genome, inputs []float64) []float64 {
-	outputs := []float64{}
-
-	for i := range inputs {
-		genome.Nodes[i].Value = inputs[i]
-	}
-
-	// hidden
-	for n := 0; n < genome.NextNodeID; n++ {
-		if genome.Nodes[n].Type != NodeTypeHidden {
-			continue
+var aggregateFunc = map[string]func(xs []float64) float64{
+	"SUM": func(xs []float64) float64 {
+		sum := 0.0
+		for _, x := range xs {
+			sum += x
 		}
-		genome.Nodes[n].Value = 0
-
-		for _, c := range genome.Connections {
-			if c.Enabled && c.Out == n {
-				genome.Nodes[c.Out].Value += genome.Nodes[c.In].Value * c.Weight
+		return sum
+	},
+	"PRODUCT": func(xs []float64) float64 {
+		product := 1.0
+		for _, x := range xs {
+			product *= x
+		}
+		return product
+	},
+	"MAX": func(xs []float64) float64 {
+		if len(xs) == 0 {
+			return 0
+		}
+		max := xs[0]
+		for _, x := range xs {
+			max = math.Max(max, x)
+		}
+		return max
+	},
+	"MIN": func(xs []float64) float64 {
+		if len(xs) == 0 {
+			return 0
+		}
+		min := xs[0]
+		for _, x := range xs {
+			min = math.Min(min, x)
+		}
+		return min
+	},
+	"MEAN": func(xs []float64) float64 {
+		if len(xs) == 0 {
+			return 0
+		}
+		sum := 0.0
+		for _, x := range xs {
+			sum += x
+		}
+		return sum / float64(len(xs))
+	},
+	"MEDIAN": func(xs []float64) float64 {
+		if len(xs) == 0 {
+			return 0
+		}
+		sort.Float64s(xs)
+		if n := len(xs); n%2 == 0 {
+			return (xs[n/2-1] + xs[n/2]) / 2
+		}
+		return xs[len(xs)/2]
+	},
+	"MAXABS": func(xs []float64) float64 {
+		maxabs := 0.0
+		for _, x := range xs {
+			if math.Abs(x) > math.Abs(maxabs) {
+				maxabs = x
 			}
 		}
+		return maxabs
+	},
+}
 
-		// genome.Nodes[n].Value = sigmoid(genome.Nodes[n].Value)
-		genome.Nodes[n].Value = activateFunc[genome.Nodes[n].Activate](genome.Nodes[n].Value)
-	}
-
-	// output
-	for n := 0; n < genome.NextNodeID; n++ {
-		if genome.Nodes[n].Type != NodeTypeOutput {
-			continue
+// randAggregateFunc returns one of Options.Aggregations, any function when
+// empty
+func (o *Population) randAggregateFunc() string {
+	ids := o.Options.Aggregations
+	if len(ids) == 0 {
+		for i := range aggregateFunc {
+			ids = append(ids, i)
 		}
-		genome.Nodes[n].Value = 0
+		sort.Strings(ids)
+	}
+	return ids[o.randIntn(0, len(ids)-1)]
+}
 
-		for _, c := range genome.Connections {
-			if c.Enabled && c.Out == n {
-				genome.Nodes[c.Out].Value += genome.Nodes[c.In].Value * c.Weight
-			}
+// randActivateFunc returns one of Options.Activations, any function when empty
+func (o *Population) randActivateFunc() string {
+	ids := o.Options.Activations
+	if len(ids) == 0 {
+		for i := range activateFunc {
+			ids = append(ids, i)
 		}
-
-		// outputs = append(outputs, sigmoid(genome.Nodes[n].Value))
-		outputs = append(outputs, activateFunc[genome.Nodes[n].Activate](genome.Nodes[n].Value))
+		sort.Strings(ids)
 	}
+	return ids[o.randIntn(0, len(ids)-1)]
+}
 
-	return outputs
+// FeedForwardNetwork compiles the genome and evaluates it once, use Compile
+// to evaluate the same genome repeatedly
+func FeedForwardNetwork(genome *Genome, inputs []float64) []float64 {
+	return Compile(genome).Activate(inputs)
 }
 
 var randBool = false
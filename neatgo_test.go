@@ -1,13 +1,20 @@
 package neatgo
 
 import (
+	"bytes"
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"math"
+	"math/rand"
+	"reflect"
 	"runtime"
+	"sort"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 // go test neatgo -run TestXOR -v -count=1
@@ -65,3 +72,1002 @@ func TestXOR(t *testing.T) {
 		}
 	}
 }
+
+func TestParallelEvaluator(t *testing.T) {
+	pop, _ := NewPopulation(2, 0, 1, 10, 4, nil)
+	pop.createGenome("")
+
+	evaluator := NewParallelEvaluator(3, func(genome *Genome) float64 {
+		switch genome {
+		case pop.genomes[0]:
+			panic("boom")
+		case pop.genomes[1]:
+			time.Sleep(time.Second)
+		}
+		return 1
+	})
+	evaluator.Timeout = 50 * time.Millisecond
+	evaluator.FailedFitness = -1
+	evaluator.FitnessFunction(pop.genomes, 0, pop)
+
+	if len(evaluator.Errors()) != 2 {
+		t.Fatalf("errors: %v", evaluator.Errors())
+	}
+	for i, g := range pop.genomes {
+		want := 1.0
+		if i < 2 {
+			want = -1
+		}
+		if g.Fitness != want {
+			t.Fatalf("genome %d fitness %v, want %v", i, g.Fitness, want)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	evaluator.Context = ctx
+	evaluator.FitnessFunction(pop.genomes, 1, pop)
+	for i, g := range pop.genomes {
+		if g.Fitness != -1 {
+			t.Fatalf("canceled genome %d fitness %v", i, g.Fitness)
+		}
+	}
+}
+
+func TestSpeciate(t *testing.T) {
+	pop, _ := NewPopulation(2, 0, 1, 10, 4, nil)
+	pop.createGenome("")
+
+	if d := pop.genomes[0].Distance(pop.genomes[0].clone()); d != 0 {
+		t.Fatalf("distance to clone: %f", d)
+	}
+
+	pop.speciate()
+	pop.shareFitness()
+	sum := 0
+	for _, s := range pop.Species {
+		sum += s.Size()
+	}
+	if sum != pop.genomeNumber {
+		t.Fatalf("species members: %d, want %d", sum, pop.genomeNumber)
+	}
+
+	sum = 0
+	for _, n := range pop.spawnCounts() {
+		sum += n
+	}
+	if sum != pop.genomeNumber {
+		t.Fatalf("spawns: %d, want %d", sum, pop.genomeNumber)
+	}
+
+	pop, _ = NewPopulation(2, 0, 1, 10, 4, &Options{AddNode: 0.2, MutateWeight: 0.2, AllConnection: true})
+	pop.createGenome("")
+	pop.speciate()
+	if len(pop.Species) == pop.genomeNumber {
+		t.Fatalf("Options literal: %d species of one genome", len(pop.Species))
+	}
+}
+
+func TestCrossover(t *testing.T) {
+	pop, _ := NewPopulation(2, 0, 1, 10, 4, nil)
+	a, _ := NewGenome(pop)
+	a.init()
+	b := a.clone()
+	a.addNode()
+	a.Fitness, b.Fitness = 2, 1
+	aJSON, bJSON := a.ToJSON(), b.ToJSON()
+
+	child := a.crossover(b)
+	if a.ToJSON() != aJSON || b.ToJSON() != bJSON {
+		t.Fatal("crossover modified a parent")
+	}
+	if len(child.Connections) != len(a.Connections) || len(child.Nodes) != len(a.Nodes) {
+		t.Fatalf("child has %d connections %d nodes, want %d %d", len(child.Connections), len(child.Nodes), len(a.Connections), len(a.Nodes))
+	}
+
+	b.Fitness = 2
+	child = b.crossover(a)
+	if len(child.Connections) != len(a.Connections) {
+		t.Fatalf("equal fitness child has %d connections, want %d", len(child.Connections), len(a.Connections))
+	}
+
+	// 2 -> 3 in one parent and 3 -> 2 in the other
+	pop.Options.ReenableRate = 1
+	a, b = &Genome{Population: pop, Nodes: map[int]*Node{}}, &Genome{Population: pop, Nodes: map[int]*Node{}}
+	for k, typ := range []string{NodeTypeInput, NodeTypeOutput, NodeTypeHidden, NodeTypeHidden} {
+		a.Nodes[k] = &Node{Index: k, Type: typ, Activate: "IDENTITY"}
+		b.Nodes[k] = &Node{Index: k, Type: typ, Activate: "IDENTITY"}
+	}
+	for _, l := range [][2]int{{0, 2}, {2, 3}, {3, 1}} {
+		a.Connections = append(a.Connections, &Connection{In: l[0], Out: l[1], Weight: 1, Enabled: true, Innovation: pop.linkInnovation(l[0], l[1])})
+	}
+	for _, l := range [][2]int{{0, 3}, {3, 2}, {2, 1}} {
+		b.Connections = append(b.Connections, &Connection{In: l[0], Out: l[1], Weight: 1, Enabled: true, Innovation: pop.linkInnovation(l[0], l[1])})
+	}
+	for i := 0; i < 20; i++ {
+		child = a.crossover(b)
+		for _, c := range child.Connections {
+			enabled := c.Enabled
+			c.Enabled = false
+			if enabled && child.createsCycle(c.In, c.Out) {
+				t.Fatalf("child connection %d -> %d closes a cycle", c.In, c.Out)
+			}
+			c.Enabled = enabled
+		}
+	}
+}
+
+func TestInnovationTracker(t *testing.T) {
+	pop, _ := NewPopulation(2, 0, 1, 10, 4, nil)
+	pop.createGenome("")
+	a, b := pop.genomes[0], pop.genomes[1]
+	for i := range a.Connections {
+		if a.Connections[i].Innovation != b.Connections[i].Innovation {
+			t.Fatal("initial genomes do not share innovation numbers")
+		}
+	}
+
+	a.Connections = a.Connections[:1]
+	b.Connections = []*Connection{b.Connections[0].Clone()}
+	a.addNode()
+	b.addNode()
+	if a.NextNodeID != b.NextNodeID {
+		t.Fatalf("split node IDs differ: %d %d", a.NextNodeID-1, b.NextNodeID-1)
+	}
+	for i := range a.Connections {
+		if a.Connections[i].Innovation != b.Connections[i].Innovation {
+			t.Fatalf("innovation %d differs: %d %d", i, a.Connections[i].Innovation, b.Connections[i].Innovation)
+		}
+	}
+}
+
+func TestRecurrentNetwork(t *testing.T) {
+	options := DefaultOptions()
+	options.Recurrent = true
+	pop, _ := NewPopulation(1, 0, 1, 10, 4, options)
+	genome, _ := NewGenome(pop)
+	genome.init()
+	genome.Nodes[1].Activate = "IDENTITY"
+	genome.Nodes[1].Bias = 0
+	genome.Connections[0].Weight = 1
+	genome.Connections = append(genome.Connections, &Connection{In: 1, Out: 1, Weight: 1, Enabled: true, Innovation: pop.linkInnovation(1, 1)})
+
+	network := NewRecurrentNetwork(genome)
+	for i, want := range []float64{1, 2, 3} {
+		if outputs := network.Activate([]float64{1}); outputs[0] != want {
+			t.Fatalf("step %d: %v, want %v", i, outputs[0], want)
+		}
+	}
+	network.Reset()
+	if outputs := network.Activate([]float64{1}); outputs[0] != 1 {
+		t.Fatalf("after reset: %v, want 1", outputs[0])
+	}
+}
+
+func TestCompile(t *testing.T) {
+	pop, _ := NewPopulation(2, 0, 1, 10, 4, nil)
+	genome, _ := NewGenome(pop)
+	genome.NextNodeID = 6
+	for k, typ := range []string{NodeTypeInput, NodeTypeInput, NodeTypeOutput, NodeTypeHidden, NodeTypeHidden, NodeTypeHidden} {
+		genome.Nodes[k] = NewNode(k, typ)
+		genome.Nodes[k].Activate = "IDENTITY"
+	}
+	for i, c := range [][2]int{{0, 4}, {4, 3}, {3, 2}, {1, 5}} {
+		genome.Connections = append(genome.Connections, &Connection{In: c[0], Out: c[1], Weight: 2, Enabled: true, Innovation: int64(i)})
+	}
+
+	network := Compile(genome)
+	if len(network.order) != 3 {
+		t.Fatalf("compiled %d nodes, want 3", len(network.order))
+	}
+	if outputs := network.Activate([]float64{1, 1}); outputs[0] != 8 {
+		t.Fatalf("output %v, want 8", outputs[0])
+	}
+	if genome.Nodes[2].Value != 0 {
+		t.Fatal("network wrote into the genome")
+	}
+}
+
+func TestCompileSparseIDs(t *testing.T) {
+	pop, _ := NewPopulation(1, 0, 1, 10, 4, nil)
+	genome, _ := NewGenome(pop)
+	for _, n := range []*Node{
+		{Index: -3, Type: NodeTypeInput, Activate: "IDENTITY", Response: 1},
+		{Index: 1 << 30, Type: NodeTypeOutput, Activate: "IDENTITY", Response: 1},
+	} {
+		genome.Nodes[n.Index] = n
+	}
+	genome.Connections = []*Connection{
+		{In: -3, Out: 1 << 30, Weight: 3, Enabled: true},
+		{In: 7, Out: 1 << 30, Weight: 5, Enabled: true},
+	}
+
+	if outputs := Compile(genome).Activate([]float64{2}); outputs[0] != 6 {
+		t.Fatalf("output %v, want 6", outputs[0])
+	}
+}
+
+// go test neatgo -run TestConcurrentNetwork -race -count=1
+func TestConcurrentNetwork(t *testing.T) {
+	options := DefaultOptions()
+	options.MaxNode = 100
+	pop, _ := NewPopulation(8, 20, 4, 10, 1, options)
+	genome, _ := NewGenome(pop)
+	genome.init()
+	network := Compile(genome)
+
+	data := [][]float64{}
+	wants := [][]float64{}
+	for i := 0; i < 50; i++ {
+		inputs := make([]float64, 8)
+		for k := range inputs {
+			inputs[k] = NeatRandom(-1, 1)
+		}
+		data = append(data, inputs)
+		wants = append(wants, network.Activate(inputs))
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan string, 3*8)
+	check := func(name string, activate func(inputs []float64) []float64) {
+		defer wg.Done()
+		for i, inputs := range data {
+			outputs := activate(inputs)
+			for k := range outputs {
+				if outputs[k] != wants[i][k] {
+					errs <- fmt.Sprintf("%s: sample %d output %d: %v, want %v", name, i, k, outputs[k], wants[i][k])
+					return
+				}
+			}
+		}
+	}
+
+	for w := 0; w < 8; w++ {
+		wg.Add(3)
+		go check("Activate", network.Activate)
+		state := network.NewState()
+		go check("ActivateState", func(inputs []float64) []float64 { return network.ActivateState(state, inputs) })
+		go check("FeedForwardNetwork", func(inputs []float64) []float64 { return FeedForwardNetwork(genome, inputs) })
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func BenchmarkNetwork(b *testing.B) {
+	options := DefaultOptions()
+	options.MaxNode = 28/2*28/2 + 10 + 50
+	pop, _ := NewPopulation(28/2*28/2, 50, 10, 10, 1, options)
+	genome, _ := NewGenome(pop)
+	genome.init()
+	inputs := make([]float64, 28/2*28/2)
+
+	b.Run("FeedForwardNetwork", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			FeedForwardNetwork(genome, inputs)
+		}
+	})
+	b.Run("Compile", func(b *testing.B) {
+		network := Compile(genome)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			network.Activate(inputs)
+		}
+	})
+}
+
+func xorEvaluator() *ParallelEvaluator {
+	data := [][]float64{{0, 0, 0}, {0, 1, 1}, {1, 0, 1}, {1, 1, 0}}
+	return NewParallelEvaluator(0, func(genome *Genome) float64 {
+		fitness := 4.0
+		network := Compile(genome)
+		for _, d := range data {
+			fitness -= math.Pow(network.Activate(d[:2])[0]-d[2], 2)
+		}
+		return fitness
+	})
+}
+
+func TestSeed(t *testing.T) {
+	run := func() string {
+		options := DefaultOptions()
+		options.Seed = 42
+		pop, _ := NewPopulation(2, 0, 1, 20, 4, options)
+		return pop.Run(xorEvaluator().FitnessFunction, 50, "").ToJSON()
+	}
+
+	if a, b := run(), run(); a != b {
+		t.Fatalf("runs with the same seed differ:\n%s\n%s", a, b)
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	options := DefaultOptions()
+	options.Seed = 7
+	options.KeepWinner = 2
+	pop, _ := NewPopulation(2, 0, 1, 20, 4, options)
+	want := pop.Run(xorEvaluator().FitnessFunction, 30, "").ToJSON()
+
+	options = DefaultOptions()
+	options.Seed = 7
+	options.KeepWinner = 2
+	pop, _ = NewPopulation(2, 0, 1, 20, 4, options)
+	pop.Run(xorEvaluator().FitnessFunction, 15, "")
+	buf := &bytes.Buffer{}
+	if err := pop.SaveCheckpoint(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, _ := NewPopulation(1, 0, 1, 5, 0, nil)
+	if err := restored.LoadCheckpoint(buf); err != nil {
+		t.Fatal(err)
+	}
+	if restored.Generation() != pop.Generation() || len(restored.Species) != len(pop.Species) {
+		t.Fatalf("restored generation %d species %d, want %d %d", restored.Generation(), len(restored.Species), pop.Generation(), len(pop.Species))
+	}
+	if got := restored.Run(xorEvaluator().FitnessFunction, 15, "").ToJSON(); got != want {
+		t.Fatalf("resumed run differs:\n%s\n%s", got, want)
+	}
+}
+
+func TestReporter(t *testing.T) {
+	options := DefaultOptions()
+	options.Seed = 1
+	pop, _ := NewPopulation(2, 0, 1, 10, 4, options)
+	csvBuf, jsonBuf, stdoutBuf := &bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}
+	pop.AddReporter(NewCSVReporter(csvBuf))
+	pop.AddReporter(NewJSONReporter(jsonBuf))
+	stdout := NewStdOutReporter(2)
+	stdout.Writer = stdoutBuf
+	pop.AddReporter(stdout)
+	pop.Run(xorEvaluator().FitnessFunction, 5, "")
+
+	if lines := strings.Count(csvBuf.String(), "\n"); lines != 6 {
+		t.Fatalf("csv has %d lines, want 6:\n%s", lines, csvBuf.String())
+	}
+	if lines := strings.Count(jsonBuf.String(), "\"end_generation\""); lines != 5 {
+		t.Fatalf("json has %d end_generation events, want 5:\n%s", lines, jsonBuf.String())
+	}
+	if lines := strings.Count(stdoutBuf.String(), " species:"); lines != 3 {
+		t.Fatalf("stdout has %d progress lines, want 3:\n%s", lines, stdoutBuf.String())
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	options := DefaultOptions()
+	options.Seed = 3
+	pop, _ := NewPopulation(2, 0, 1, 10, 4, options)
+	ctx, cancel := context.WithCancel(context.Background())
+	evaluator := xorEvaluator()
+	fitnessFunction := func(genomes []*Genome, generation int, population *Population) {
+		if generation == 3 {
+			cancel()
+		}
+		evaluator.FitnessFunction(genomes, generation, population)
+	}
+
+	winner, reason := pop.RunContext(ctx, fitnessFunction, -1, "")
+	if reason != StopCanceled || winner == nil || pop.Generation() != 3 {
+		t.Fatalf("reason %q winner %v generation %d", reason, winner != nil, pop.Generation())
+	}
+	if len(evaluator.Errors()) == 0 {
+		t.Fatal("evaluation did not see the canceled context")
+	}
+
+	_, reason = pop.RunContext(context.Background(), evaluator.FitnessFunction, 2, "")
+	if reason != StopGenerations || pop.Generation() != 4 {
+		t.Fatalf("reason %q generation %d", reason, pop.Generation())
+	}
+}
+
+func TestTermination(t *testing.T) {
+	tests := []struct {
+		criterion  TerminationCriterion
+		reason     StopReason
+		generation int
+	}{
+		{MaxEvaluations(30), StopMaxEvaluations, 2},
+		{Predicate(func(p *Population) bool { return p.Generation() == 4 }), StopPredicate, 4},
+		{AllOf{MaxEvaluations(20), Predicate(func(p *Population) bool { return p.Generation() == 5 })}, StopMaxEvaluations + ", " + StopPredicate, 5},
+		{MeanFitness(-1), StopMeanFitness, 0},
+		{TimeBudget(0), StopTimeBudget, 0},
+	}
+	for _, test := range tests {
+		options := DefaultOptions()
+		options.Seed = 5
+		pop, _ := NewPopulation(2, 0, 1, 10, 100, options)
+		pop.AddTermination(test.criterion)
+		_, reason := pop.RunContext(context.Background(), xorEvaluator().FitnessFunction, 100, "")
+		if reason != test.reason || pop.Generation() != test.generation {
+			t.Errorf("%T: reason %q generation %d, want %q %d", test.criterion, reason, pop.Generation(), test.reason, test.generation)
+		}
+	}
+}
+
+type stagnationReporter struct {
+	BaseReporter
+	stagnant int
+}
+
+func (o *stagnationReporter) SpeciesStagnant(population *Population, species *Species) {
+	o.stagnant++
+}
+
+func TestStagnation(t *testing.T) {
+	constant := func(genomes []*Genome, generation int, population *Population) {
+		for _, g := range genomes {
+			g.Fitness = 1
+		}
+	}
+
+	options := DefaultOptions()
+	options.MaxStagnation = 2
+	options.SpeciesElitism = 0
+	pop, _ := NewPopulation(2, 0, 1, 10, 4, options)
+	reporter := &stagnationReporter{}
+	pop.AddReporter(reporter)
+	if _, reason := pop.RunContext(context.Background(), constant, 10, ""); reason != StopSpeciesExtinct || reporter.stagnant == 0 {
+		t.Fatalf("reason %q stagnant %d", reason, reporter.stagnant)
+	}
+
+	options.SpeciesElitism = 1
+	pop, _ = NewPopulation(2, 0, 1, 10, 4, options)
+	if _, reason := pop.RunContext(context.Background(), constant, 10, ""); reason != StopGenerations || len(pop.Species) == 0 {
+		t.Fatalf("elitism: reason %q species %d", reason, len(pop.Species))
+	}
+
+	options.SpeciesElitism = 0
+	options.ResetOnExtinction = true
+	pop, _ = NewPopulation(2, 0, 1, 10, 4, options)
+	if _, reason := pop.RunContext(context.Background(), constant, 10, ""); reason != StopGenerations || len(pop.genomes) != 10 {
+		t.Fatalf("reset: reason %q genomes %d", reason, len(pop.genomes))
+	}
+}
+
+func TestSelector(t *testing.T) {
+	options := DefaultOptions()
+	options.Seed = 11
+	pop, _ := NewPopulation(2, 0, 1, 10, 4, options)
+	candidates := Genomes{}
+	for i := 0; i < 10; i++ {
+		g, _ := NewGenome(pop)
+		g.Fitness = float64(10 - i)
+		candidates = append(candidates, g)
+	}
+
+	selectors := []Selector{TruncationSelector{Threshold: 0.2}, TournamentSelector{Size: 3}, RouletteSelector{}, RankSelector{}}
+	for _, selector := range selectors {
+		counts := map[*Genome]int{}
+		for i := 0; i < 1000; i++ {
+			counts[selector.Select(pop, candidates)]++
+		}
+		if counts[candidates[0]] <= counts[candidates[len(candidates)-1]] {
+			t.Errorf("%T: best chosen %d times, worst %d times", selector, counts[candidates[0]], counts[candidates[len(candidates)-1]])
+		}
+		if _, ok := selector.(TruncationSelector); ok && len(counts) != 2 {
+			t.Errorf("truncation chose %d genomes, want 2", len(counts))
+		}
+	}
+}
+
+func TestSortGenomes(t *testing.T) {
+	genomes := Genomes{}
+	for i := 0; i < 10; i++ {
+		g, _ := NewGenome(nil)
+		genomes = append(genomes, g)
+	}
+	sort.Sort(genomes)
+}
+
+func TestElitism(t *testing.T) {
+	options := DefaultOptions()
+	options.Elitism = 1
+	options.CrossoverRate = 0
+	options.MutateWeight = 1
+	pop, _ := NewPopulation(2, 0, 1, 6, 4, options)
+	pop.createGenome("")
+	for i, g := range pop.genomes {
+		g.Fitness = float64(i)
+	}
+	pop.sortWinners(0)
+	pop.speciate()
+	best := pop.Winners[0].ToJSON()
+	pop.next(0)
+
+	if len(pop.genomes) != 6 {
+		t.Fatalf("next generation has %d genomes, want 6", len(pop.genomes))
+	}
+	found := false
+	for _, g := range pop.genomes {
+		found = found || g.ToJSON() == best
+	}
+	if !found {
+		t.Fatal("elite was not copied unmodified")
+	}
+
+	// a single genome is never kept only as an elite
+	pop, _ = NewPopulation(2, 0, 1, 1, 4, options)
+	pop.createGenome("")
+	pop.sortWinners(0)
+	pop.speciate()
+	best = pop.Winners[0].ToJSON()
+	pop.next(0)
+	if len(pop.genomes) != 1 || pop.genomes[0].ToJSON() == best {
+		t.Fatal("population of one was not mutated")
+	}
+}
+
+func TestDeleteMutation(t *testing.T) {
+	pop, _ := NewPopulation(2, 3, 1, 10, 4, nil)
+	genome, _ := NewGenome(pop)
+	genome.init()
+
+	for i := 0; i < 20; i++ {
+		genome.deleteNode()
+		genome.deleteConnection()
+		for _, c := range genome.Connections {
+			if genome.Nodes[c.In] == nil || genome.Nodes[c.Out] == nil {
+				t.Fatalf("dangling connection %d -> %d", c.In, c.Out)
+			}
+		}
+	}
+	if len(genome.Nodes) != 3 || len(genome.Connections) != 0 {
+		t.Fatalf("%d nodes %d connections left, want 3 0", len(genome.Nodes), len(genome.Connections))
+	}
+	genome.addNode()
+	Compile(genome).Activate([]float64{1, 1})
+}
+
+func TestToggleEnable(t *testing.T) {
+	pop, _ := NewPopulation(1, 0, 1, 10, 4, nil)
+	genome, _ := NewGenome(pop)
+	genome.init()
+	genome.addNode()
+	hidden := genome.NextNodeID - 1
+	back := &Connection{In: 1, Out: hidden, Weight: 1, Innovation: pop.linkInnovation(1, hidden)}
+	genome.Connections = []*Connection{genome.Connections[1], genome.Connections[2], back}
+
+	for i := 0; i < 50; i++ {
+		genome.toggleEnable()
+		if back.Enabled {
+			t.Fatal("enabled a connection closing a cycle")
+		}
+		genome.Connections[0].Enabled, genome.Connections[1].Enabled = true, true
+	}
+
+	pop.Options.Recurrent = true
+	for i := 0; i < 50 && !back.Enabled; i++ {
+		genome.toggleEnable()
+	}
+	if !back.Enabled {
+		t.Fatal("recurrent mode never enabled the connection")
+	}
+}
+
+func TestActivationMutation(t *testing.T) {
+	options := DefaultOptions()
+	options.HiddenActivation = "RELU"
+	options.OutputActivation = "IDENTITY"
+	pop, _ := NewPopulation(2, 1, 1, 10, 4, options)
+	a, _ := NewGenome(pop)
+	a.init()
+	if a.Nodes[2].Activate != "IDENTITY" || a.Nodes[3].Activate != "RELU" {
+		t.Fatalf("activations %q %q, want IDENTITY RELU", a.Nodes[2].Activate, a.Nodes[3].Activate)
+	}
+
+	b := a.clone()
+	options.ActivationMutateRate = 1
+	options.Activations = []string{"TANH"}
+	b.mutateActivation()
+	for _, n := range b.Nodes {
+		if n.Type != NodeTypeInput && n.Activate != "TANH" {
+			t.Fatalf("node %d activation %q, want TANH", n.Index, n.Activate)
+		}
+	}
+
+	a.Fitness, b.Fitness = 1, 1
+	seen := map[string]bool{}
+	for i := 0; i < 50; i++ {
+		seen[a.crossover(b).Nodes[3].Activate] = true
+	}
+	if !seen["RELU"] || !seen["TANH"] {
+		t.Fatalf("crossover inherited %v, want both parents", seen)
+	}
+}
+
+func TestBias(t *testing.T) {
+	pop, _ := NewPopulation(1, 0, 1, 10, 4, nil)
+	genome, _ := NewGenome(pop)
+	if err := genome.LoadJSON(`{"Nodes":{"0":{"Index":0,"Type":"input","Activate":""},"1":{"Index":1,"Type":"output","Activate":"IDENTITY"}},"Connections":[{"In":0,"Out":1,"Weight":2,"Enabled":true,"Innovation":0}],"NextNodeID":2,"Fitness":0}`); err != nil {
+		t.Fatal(err)
+	}
+	if outputs := Compile(genome).Activate([]float64{1}); outputs[0] != 2 {
+		t.Fatalf("old JSON output %v, want 2", outputs[0])
+	}
+
+	genome.Nodes[1].Bias = 0.5
+	loaded, _ := NewGenome(pop)
+	loaded.LoadJSON(genome.ToJSON())
+	if outputs := Compile(loaded).Activate([]float64{1}); outputs[0] != 2.5 {
+		t.Fatalf("output %v, want 2.5", outputs[0])
+	}
+	if outputs := NewRecurrentNetwork(loaded).Activate([]float64{1}); outputs[0] != 2.5 {
+		t.Fatalf("recurrent output %v, want 2.5", outputs[0])
+	}
+}
+
+func TestAggregation(t *testing.T) {
+	pop, _ := NewPopulation(3, 0, 1, 10, 4, nil)
+	genome, _ := NewGenome(pop)
+	genome.init()
+	genome.Nodes[3].Activate = "IDENTITY"
+	for i, c := range genome.Connections {
+		c.Weight = float64(i + 1)
+	}
+
+	inputs := []float64{1, -4, 1}
+	for name, want := range map[string]float64{"SUM": 1 - 8 + 3, "PRODUCT": -24, "MAX": 3, "MIN": -8, "MEAN": -4.0 / 3, "MEDIAN": 1, "MAXABS": -8} {
+		genome.Nodes[3].Aggregation = name
+		genome.Nodes[3].Response = 0.5
+		genome.Nodes[3].Bias = 0
+		if outputs := Compile(genome).Activate(inputs); outputs[0] != want*0.5 {
+			t.Errorf("%s: %v, want %v", name, outputs[0], want*0.5)
+		}
+		if outputs := NewRecurrentNetwork(genome).Activate(inputs); outputs[0] != want*0.5 {
+			t.Errorf("%s recurrent: %v, want %v", name, outputs[0], want*0.5)
+		}
+	}
+
+	options := pop.Options
+	options.AggregationMutateRate = 1
+	options.Aggregations = []string{"MAX"}
+	genome.mutateAggregation()
+	if genome.Nodes[3].Aggregation != "MAX" {
+		t.Fatalf("aggregation %q, want MAX", genome.Nodes[3].Aggregation)
+	}
+
+	loaded, _ := NewGenome(pop)
+	loaded.LoadJSON(`{"Nodes":{"0":{"Index":0,"Type":"input"},"1":{"Index":1,"Type":"output","Activate":"IDENTITY"}},"Connections":[{"In":0,"Out":1,"Weight":2,"Enabled":true}]}`)
+	if loaded.Nodes[1].Response != 1 || loaded.Nodes[1].Aggregation != "SUM" {
+		t.Fatalf("old JSON loaded response %v aggregation %q", loaded.Nodes[1].Response, loaded.Nodes[1].Aggregation)
+	}
+}
+
+func TestOptionsLiteral(t *testing.T) {
+	pop, err := NewPopulation(2, 0, 1, 10, 4, &Options{
+		KeepWinner:    0,
+		AddNode:       0.2,
+		AddConnection: 0.2,
+		MutateWeight:  0.2,
+		MaxDistance:   2,
+		AllConnection: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if defaults := DefaultOptions(); pop.Options.CrossoverRate != defaults.CrossoverRate || pop.Options.WinnerPool != defaults.WinnerPool {
+		t.Fatalf("crossover rate %v winner pool %d not defaulted", pop.Options.CrossoverRate, pop.Options.WinnerPool)
+	}
+	// all weights 0 give 0.5 for every input, a fitness of 3
+	winner := pop.Run(xorEvaluator().FitnessFunction, 20, "")
+	if winner.Fitness == 3 {
+		t.Fatal("fitness did not move")
+	}
+	for _, g := range pop.Genomes() {
+		for _, node := range g.Nodes {
+			if node.Type != NodeTypeInput && node.Response == 0 {
+				t.Fatalf("node %d has no response", node.Index)
+			}
+		}
+	}
+}
+
+func TestWeightMutation(t *testing.T) {
+	options := DefaultOptions()
+	options.MutateWeight = 1
+	options.WeightMutatePower = 100
+	options.WeightMinValue, options.WeightMaxValue = -5, 5
+	pop, _ := NewPopulation(3, 0, 2, 10, 4, options)
+	genome, _ := NewGenome(pop)
+	genome.init()
+	for i := 0; i < 100; i++ {
+		genome.mutateWeight()
+		for _, c := range genome.Connections {
+			if c.Weight < -5 || c.Weight > 5 {
+				t.Fatalf("weight %v out of [-5, 5]", c.Weight)
+			}
+		}
+	}
+
+	options.InitType = "uniform"
+	options.WeightInitMean, options.WeightInitStdev = 10, 1
+	options.WeightMinValue, options.WeightMaxValue = 0, 100
+	for i := 0; i < 100; i++ {
+		if w := options.weightGene().init(pop); w < 8 || w > 12 {
+			t.Fatalf("uniform weight %v out of [8, 12]", w)
+		}
+	}
+}
+
+func TestAddConnection(t *testing.T) {
+	options := DefaultOptions()
+	options.AllConnection = false
+	pop, _ := NewPopulation(2, 0, 1, 10, 4, options)
+	genome, _ := NewGenome(pop)
+	genome.init()
+	genome.addNode()
+	genome.addNode()
+	for i := 0; i < 100; i++ {
+		genome.addConnection()
+	}
+
+	links := map[[2]int]bool{}
+	for _, c := range genome.Connections {
+		if links[[2]int{c.In, c.Out}] {
+			t.Fatalf("duplicate connection %d -> %d", c.In, c.Out)
+		}
+		links[[2]int{c.In, c.Out}] = true
+		if genome.Nodes[c.Out].Type == NodeTypeInput || genome.Nodes[c.In].Type == NodeTypeOutput {
+			t.Fatalf("invalid connection %d -> %d", c.In, c.Out)
+		}
+	}
+	// 2 inputs and 2 hidden nodes to the output and 2 inputs to the hidden
+	// nodes, plus one link between the hidden nodes
+	if len(links) != 4+4+1 {
+		t.Fatalf("%d connections, want 9", len(links))
+	}
+	for _, c := range genome.Connections {
+		enabled := c.Enabled
+		c.Enabled = false
+		if genome.createsCycle(c.In, c.Out) {
+			t.Fatalf("connection %d -> %d closes a cycle", c.In, c.Out)
+		}
+		c.Enabled = enabled
+	}
+}
+
+func TestAddNode(t *testing.T) {
+	options := DefaultOptions()
+	options.MaxNode = 3
+	pop, _ := NewPopulation(2, 0, 1, 10, 4, options)
+	genome, _ := NewGenome(pop)
+	genome.init()
+	for i := 0; i < 3; i++ {
+		if err := genome.addNode(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := genome.addNode(); err != errMaxNode {
+		t.Fatalf("addNode over MaxNode: %v", err)
+	}
+	disabled := 0
+	for _, c := range genome.Connections {
+		if !c.Enabled {
+			disabled++
+		}
+	}
+	if disabled != 3 {
+		t.Fatalf("%d disabled connections, want 3 split once each", disabled)
+	}
+
+	genome, _ = NewGenome(pop)
+	genome.init()
+	for _, c := range genome.Connections {
+		c.Enabled = false
+	}
+	if err := genome.addNode(); err != errNoSplit || genome.hiddenNumber() != 0 {
+		t.Fatalf("addNode without enabled connection: %v", err)
+	}
+}
+
+func TestMutator(t *testing.T) {
+	options := DefaultOptions()
+	options.SingleStructuralMutation = false
+	options.AddNode, options.AddConnection = 1, 1
+	options.DeleteNode, options.DeleteConnection = 0, 0
+	options.MaxDistance = 0
+	options.MaxNode = 100
+	options.AddConnectionTries = 1000
+	pop, _ := NewPopulation(2, 0, 1, 10, 4, options)
+	genome, _ := NewGenome(pop)
+	genome.init()
+	genome.nextGeneration(0)
+	if genome.hiddenNumber() != 1 || len(genome.Connections) != 5 {
+		t.Fatalf("%d hidden nodes %d connections, want 1 5", genome.hiddenNumber(), len(genome.Connections))
+	}
+
+	calls := 0
+	pop.AddMutator(MutatorFunc(func(genome *Genome, rnd *rand.Rand) {
+		if rnd != pop.Rand() {
+			t.Error("mutator did not get the population generator")
+		}
+		calls++
+	}))
+	pop.Run(func(genomes []*Genome, generation int, population *Population) {}, 3, "")
+	if calls == 0 {
+		t.Fatal("mutator not called")
+	}
+}
+
+func TestConfig(t *testing.T) {
+	options := DefaultOptions()
+	options.AddNode = 1.5
+	if err := options.Validate(); err == nil {
+		t.Fatal("AddNode 1.5 is valid")
+	}
+	if _, err := NewPopulation(2, 0, 1, 10, 4, options); err == nil {
+		t.Fatal("NewPopulation accepted invalid options")
+	}
+	options = DefaultOptions()
+	options.Activations = []string{"NOPE"}
+	if err := options.Validate(); err == nil {
+		t.Fatal("unknown activation is valid")
+	}
+	for name, change := range map[string]func(o *Options){
+		"CompatibilityThreshold 0": func(o *Options) { o.CompatibilityThreshold = 0 },
+		"WeightMinValue > Max":     func(o *Options) { o.WeightMinValue, o.WeightMaxValue = 1, -1 },
+		"BiasMinValue > Max":       func(o *Options) { o.BiasMinValue = 31 },
+		"ResponseMinValue > Max":   func(o *Options) { o.ResponseMaxValue = -31 },
+		"WeightMutatePower 0":      func(o *Options) { o.WeightMutatePower = 0 },
+		"BiasMutatePower 0":        func(o *Options) { o.BiasMutatePower = 0 },
+		"ResponseMutatePower 0":    func(o *Options) { o.ResponseMutateRate, o.ResponseMutatePower = 0.1, 0 },
+	} {
+		options = DefaultOptions()
+		change(options)
+		if err := options.Validate(); err == nil {
+			t.Fatalf("%s is valid", name)
+		}
+	}
+	config := &Config{InputNumber: 2, OutputNumber: 1, GenomeNumber: 10, HiddenNumber: 20, Options: DefaultOptions()}
+	if err := config.Validate(); err == nil {
+		t.Fatal("HiddenNumber over MaxNode is valid")
+	}
+	if _, err := NewPopulation(2, 20, 1, 10, 4, nil); err == nil {
+		t.Fatal("NewPopulation accepted HiddenNumber over MaxNode")
+	}
+	if _, err := NewPopulation(0, 0, 0, 10, 4, nil); err == nil {
+		t.Fatal("NewPopulation accepted no inputs and outputs")
+	}
+
+	config.HiddenNumber, config.FitnessThreshold = 1, 3.9
+	config.Options.Elitism = 3
+	buf := &bytes.Buffer{}
+	if err := config.Save(buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadConfig(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, config) {
+		t.Fatalf("loaded %+v, want %+v", loaded, config)
+	}
+	if _, err := LoadConfig(strings.NewReader(`{"InputNumber":2,"OutputNumber":1,"GenomeNumber":10,"Options":{"Elitism":2}}`)); err != nil {
+		t.Fatalf("partial options: %v", err)
+	}
+
+	config, err = LoadNEATConfig(strings.NewReader(neatConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	options = config.Options
+	if config.InputNumber != 2 || config.OutputNumber != 1 || config.GenomeNumber != 150 || config.FitnessThreshold != 3.9 {
+		t.Fatalf("sizes %+v", config)
+	}
+	if options.HiddenActivation != "LOGISTIC" || !reflect.DeepEqual(options.Activations, []string{"LOGISTIC", "TANH"}) || options.MutateWeight != 0.8 ||
+		options.CompatibilityWeight != 0.5 || options.MaxStagnation != 20 || options.SurvivalThreshold != 0.2 || options.Recurrent {
+		t.Fatalf("options %+v", options)
+	}
+	pop, err := config.NewPopulation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pop.Run(xorEvaluator().FitnessFunction, 5, "")
+	config, err = LoadNEATConfig(strings.NewReader(strings.Replace(neatConfig, "num_hidden              = 0", "num_hidden = 12", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := config.NewPopulation(); err != nil || config.HiddenNumber != 12 {
+		t.Fatalf("num_hidden 12: hidden %d, %v", config.HiddenNumber, err)
+	}
+
+	if _, err := LoadNEATConfig(strings.NewReader("[NEAT]\npop_size = many\n")); err == nil {
+		t.Fatal("invalid pop_size loaded")
+	}
+	for old, change := range map[string]string{
+		"activation_options      = sigmoid": "activation_options = sin",
+		"weight_init_mean        = 0.0":     "weight_init_type = uniform\nbias_init_type = gaussian",
+		"aggregation_default     = sum":     "aggregation_default = product",
+	} {
+		if _, err := LoadNEATConfig(strings.NewReader(strings.Replace(neatConfig, old, change, 1))); err == nil {
+			t.Fatalf("%q loaded", change)
+		}
+	}
+}
+
+const neatConfig = `
+#--- parameters for the XOR-2 experiment ---#
+
+[NEAT]
+fitness_criterion     = max
+fitness_threshold     = 3.9
+pop_size              = 150
+reset_on_extinction   = False
+
+[DefaultGenome]
+# node activation options
+activation_default      = sigmoid
+activation_mutate_rate  = 0.0
+activation_options      = sigmoid
+                          tanh
+
+# node aggregation options
+aggregation_default     = sum
+aggregation_mutate_rate = 0.0
+aggregation_options     = sum
+
+# node bias options
+bias_init_mean          = 0.0
+bias_init_stdev         = 1.0
+bias_max_value          = 30.0
+bias_min_value          = -30.0
+bias_mutate_power       = 0.5
+bias_mutate_rate        = 0.7
+bias_replace_rate       = 0.1
+
+# genome compatibility options
+compatibility_disjoint_coefficient = 1.0
+compatibility_weight_coefficient   = 0.5
+
+# connection add/remove rates
+conn_add_prob           = 0.5
+conn_delete_prob        = 0.5
+
+# connection enable options
+enabled_default         = True
+enabled_mutate_rate     = 0.01
+
+feed_forward            = True
+initial_connection      = full
+
+# node add/remove rates
+node_add_prob           = 0.2
+node_delete_prob        = 0.2
+
+# network parameters
+num_hidden              = 0
+num_inputs              = 2
+num_outputs             = 1
+
+# node response options
+response_init_mean      = 1.0
+response_init_stdev     = 0.0
+response_max_value      = 30.0
+response_min_value      = -30.0
+response_mutate_power   = 0.0
+response_mutate_rate    = 0.0
+response_replace_rate   = 0.0
+
+# connection weight options
+weight_init_mean        = 0.0
+weight_init_stdev       = 1.0
+weight_max_value        = 30
+weight_min_value        = -30
+weight_mutate_power     = 0.5
+weight_mutate_rate      = 0.8
+weight_replace_rate     = 0.1
+
+[DefaultSpeciesSet]
+compatibility_threshold = 3.0
+
+[DefaultStagnation]
+species_fitness_func = max
+max_stagnation       = 20
+species_elitism      = 2
+
+[DefaultReproduction]
+elitism            = 2
+survival_threshold = 0.2
+`
@@ -0,0 +1,198 @@
+package neatgo
+
+import (
+	"sort"
+	"sync"
+)
+
+// Network is a compiled feed-forward phenotype of a genome. Nodes are
+// topologically sorted, nodes that can't reach an output are pruned and the
+// enabled connections are stored as flat slices. A Network is never modified
+// after Compile and the node values live in a NetworkState, never in the
+// genome, so one genome can be evaluated by many goroutines at once.
+type Network struct {
+	inputs     []int
+	outputs    []int
+	order      []int
+	activates  []func(x float64) float64
+	aggregates []func(xs []float64) float64 // nil sums
+	bias       []float64
+	response   []float64
+	start      []int
+	in         []int
+	weight     []float64
+	size       int
+	maxIn      int
+	states     sync.Pool
+}
+
+// NetworkState holds the node values of one evaluation
+type NetworkState struct {
+	values []float64
+	inputs []float64
+}
+
+// Compile ...
+func Compile(genome *Genome) *Network {
+	ids := genome.nodeIDs()
+	// slot maps a node ID to its index in ids, -1 when the genome has no such
+	// node. IDs are mostly dense, so try the ID itself before searching
+	slot := func(id int) int {
+		if id >= 0 && id < len(ids) && ids[id] == id {
+			return id
+		}
+		if i := sort.SearchInts(ids, id); i < len(ids) && ids[i] == id {
+			return i
+		}
+		return -1
+	}
+
+	// enabled connections grouped by the slot of their output node
+	type link struct {
+		in     int
+		weight float64
+	}
+	connections := genome.sortedConnections()
+	start := make([]int, len(ids)+2)
+	for _, c := range connections {
+		if c.Enabled && slot(c.In) >= 0 {
+			if out := slot(c.Out); out >= 0 {
+				start[out+2]++
+			}
+		}
+	}
+	for i := 2; i < len(start); i++ {
+		start[i] += start[i-1]
+	}
+	incoming := make([]link, start[len(start)-1])
+	for _, c := range connections {
+		if !c.Enabled {
+			continue
+		}
+		if in, out := slot(c.In), slot(c.Out); in >= 0 && out >= 0 {
+			incoming[start[out+1]] = link{in: in, weight: c.Weight}
+			start[out+1]++
+		}
+	}
+
+	o := &Network{
+		size:   len(ids),
+		start:  make([]int, 1, len(ids)+1),
+		order:  make([]int, 0, len(ids)),
+		in:     make([]int, 0, len(incoming)),
+		weight: make([]float64, 0, len(incoming)),
+	}
+	for i, id := range ids {
+		switch genome.Nodes[id].Type {
+		case NodeTypeInput:
+			o.inputs = append(o.inputs, i)
+		case NodeTypeOutput:
+			o.outputs = append(o.outputs, i)
+		}
+	}
+
+	// depth first from the outputs: post order is a topological order, nodes
+	// never reached can't affect an output, links back into the current path
+	// would close a cycle and are dropped
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make([]byte, len(ids))
+	var visit func(n int)
+	visit = func(n int) {
+		state[n] = visiting
+		links := incoming[start[n]:start[n+1]]
+		for _, l := range links {
+			if state[l.in] == 0 {
+				visit(l.in)
+			}
+		}
+
+		node := genome.Nodes[ids[n]]
+		if node.Type != NodeTypeInput {
+			o.order = append(o.order, n)
+			o.activates = append(o.activates, activateFunc[node.Activate])
+			o.aggregates = append(o.aggregates, aggregation(node.Aggregation))
+			o.bias = append(o.bias, node.Bias)
+			o.response = append(o.response, node.Response)
+			count := 0
+			for _, l := range links {
+				// ancestors on the current path are still visiting
+				if state[l.in] != visiting {
+					o.in = append(o.in, l.in)
+					o.weight = append(o.weight, l.weight)
+					count++
+				}
+			}
+			o.start = append(o.start, len(o.in))
+			if count > o.maxIn {
+				o.maxIn = count
+			}
+		}
+		state[n] = done
+	}
+	for _, n := range o.outputs {
+		if state[n] == 0 {
+			visit(n)
+		}
+	}
+
+	return o
+}
+
+// NewState returns a state for ActivateState, owned by a single goroutine
+func (o *Network) NewState() *NetworkState {
+	return &NetworkState{values: make([]float64, o.size), inputs: make([]float64, 0, o.maxIn)}
+}
+
+// Activate is safe for concurrent use
+func (o *Network) Activate(inputs []float64) []float64 {
+	state, ok := o.states.Get().(*NetworkState)
+	if !ok {
+		state = o.NewState()
+	}
+	outputs := o.ActivateState(state, inputs)
+	o.states.Put(state)
+	return outputs
+}
+
+// ActivateState evaluates the network using the node values of state
+func (o *Network) ActivateState(state *NetworkState, inputs []float64) []float64 {
+	values := state.values
+	for i, n := range o.inputs {
+		if i < len(inputs) {
+			values[n] = inputs[i]
+		}
+	}
+
+	for i, n := range o.order {
+		x := 0.0
+		if aggregate := o.aggregates[i]; aggregate == nil {
+			for j := o.start[i]; j < o.start[i+1]; j++ {
+				x += values[o.in[j]] * o.weight[j]
+			}
+		} else {
+			xs := state.inputs[:0]
+			for j := o.start[i]; j < o.start[i+1]; j++ {
+				xs = append(xs, values[o.in[j]]*o.weight[j])
+			}
+			x = aggregate(xs)
+		}
+		values[n] = o.activates[i](o.bias[i] + o.response[i]*x)
+	}
+
+	outputs := make([]float64, len(o.outputs))
+	for i, n := range o.outputs {
+		outputs[i] = values[n]
+	}
+	return outputs
+}
+
+// aggregation returns the aggregation function by name, nil for SUM
+func aggregation(name string) func(xs []float64) float64 {
+	if name == "" || name == "SUM" {
+		return nil
+	}
+	return aggregateFunc[name]
+}
@@ -1,5 +1,7 @@
 package neatgo
 
+import "encoding/json"
+
 // ...
 const (
 	NodeTypeInput  = "input"
@@ -7,10 +9,45 @@ const (
 	NodeTypeOutput = "output"
 )
 
-// Node ...
+// Node is evaluated as Activate(Bias + Response * Aggregation(inputs)). An
+// empty Aggregation sums. Response is used as is, a Node built by hand must
+// set it, NewNode sets it to 1.
 type Node struct {
-	Index    int
-	Type     string
-	Activate string
-	Value    float64 `json:"-"`
+	Index       int
+	Type        string
+	Activate    string
+	Aggregation string
+	Bias        float64
+	Response    float64
+	Value       float64 `json:"-"`
+}
+
+// NewNode returns a node summing its inputs with a response of 1
+func NewNode(index int, typ string) *Node {
+	return &Node{Index: index, Type: typ, Aggregation: "SUM", Response: 1}
+}
+
+// Clone ...
+func (o Node) Clone() *Node {
+	return &Node{
+		Index:       o.Index,
+		Type:        o.Type,
+		Activate:    o.Activate,
+		Aggregation: o.Aggregation,
+		Bias:        o.Bias,
+		Response:    o.Response,
+		Value:       o.Value,
+	}
+}
+
+// UnmarshalJSON defaults Aggregation to SUM and Response to 1 for genomes
+// saved without them
+func (o *Node) UnmarshalJSON(data []byte) error {
+	type node Node
+	n := node{Aggregation: "SUM", Response: 1}
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	*o = Node(n)
+	return nil
 }
@@ -1,8 +1,13 @@
 package neatgo
 
 import (
+	"context"
+	"log"
 	"math"
+	"math/rand"
 	"sort"
+	"strconv"
+	"time"
 )
 
 // Population ...
@@ -14,19 +19,50 @@ type Population struct {
 	fitnessThreshold float64
 	nextInnovationID int64
 	Winners          Genomes
+	Species          []*Species
 	Options          *Options
+	Selector         Selector // chooses the parents, TruncationSelector by default
 
-	genomes Genomes
+	genomes       Genomes
+	nextSpeciesID int
+	nextNodeID    int
+	innovations   *innovationTracker
+	rand          *rand.Rand
+	source        *randSource
+
+	generation  int
+	dis         int
+	lastFitness float64
+	keep        int
+	evaluated   bool
+	reporters   []Reporter
+	mutators    []Mutator
+	ctx         context.Context
+
+	terminations    []TerminationCriterion
+	started         time.Time
+	evaluations     int
+	bestFitness     float64
+	lastImprovement int
 }
 
 // NewPopulation ...
 func NewPopulation(inputNumber, hiddenNumber, outputNumber, genomeNumber int, fitnessThreshold float64, options *Options) (*Population, error) {
-	if genomeNumber < 5 {
-		genomeNumber = 5
-	}
 	if options == nil {
 		options = DefaultOptions()
 	}
+	options.setDefaults()
+	config := &Config{
+		InputNumber:      inputNumber,
+		HiddenNumber:     hiddenNumber,
+		OutputNumber:     outputNumber,
+		GenomeNumber:     genomeNumber,
+		FitnessThreshold: fitnessThreshold,
+		Options:          options,
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
 	o := &Population{
 		inputNumber:      inputNumber,
 		hiddenNumber:     hiddenNumber,
@@ -36,43 +72,142 @@ func NewPopulation(inputNumber, hiddenNumber, outputNumber, genomeNumber int, fi
 		nextInnovationID: 0,
 		Winners:          []*Genome{},
 		Options:          options,
+		nextNodeID:       inputNumber + outputNumber,
+		innovations:      newInnovationTracker(),
 	}
+	o.initRand()
 	return o, nil
 }
 
-// Run ...
+// StopReason tells why RunContext stopped
+type StopReason string
+
+// ...
+const (
+	StopGenerations      StopReason = "generations"
+	StopFitnessThreshold StopReason = "fitness threshold"
+	StopCanceled         StopReason = "canceled"
+)
+
+// Run evolves the population for the given number of generations, -1 runs
+// until fitnessThreshold or another TerminationCriterion is reached. A population restored by LoadCheckpoint
+// continues where it stopped, initJSON is then ignored.
 func (o *Population) Run(fitnessFunction FitnessFunction, generations int, initJSON string) *Genome {
-	o.createGenome(initJSON)
+	winner, _ := o.RunContext(context.Background(), fitnessFunction, generations, initJSON)
+	return winner
+}
+
+// RunContext is Run stopping when ctx is done. The context is checked between
+// generations and available to the fitness function by Population.Context.
+// A generation interrupted during the evaluation is discarded and evaluated
+// again by the next run. It returns the best genome so far, nil when none
+// was evaluated.
+func (o *Population) RunContext(ctx context.Context, fitnessFunction FitnessFunction, generations int, initJSON string) (*Genome, StopReason) {
+	o.ctx = ctx
+	o.started = time.Now()
+	defer func() { o.ctx = nil }()
+
+	if len(o.genomes) == 0 {
+		o.createGenome(initJSON)
+	}
 	if generations < 0 {
 		generations = math.MaxInt32
 	}
-	dis, last, keep := 0, 0.0, 0
 	for n := 0; n < generations; n++ {
-		fitnessFunction(o.genomes, n, o)
+		if ctx.Err() != nil {
+			return o.best(), StopCanceled
+		}
+		if o.evaluated {
+			o.breed()
+		}
 
-		o.sortWinners(keep)
-		if n+1 == generations {
-			break
+		for _, r := range o.reporters {
+			r.StartGeneration(o)
 		}
-		if o.Winners[0].Fitness >= o.fitnessThreshold {
-			break
+
+		fitnessFunction(o.genomes, o.generation, o)
+		if ctx.Err() != nil {
+			return o.best(), StopCanceled
 		}
 
-		if last < o.Winners[0].Fitness {
-			keep = o.Options.KeepWinner
-			dis = 0
-		} else {
-			dis++
+		o.sortWinners(o.keep)
+		o.speciate()
+		o.removeStagnant()
+		if o.evaluations == 0 || o.Winners[0].Fitness > o.bestFitness {
+			o.bestFitness = o.Winners[0].Fitness
+			o.lastImprovement = o.generation
+		}
+		o.evaluations += len(o.genomes)
+		o.evaluated = true
+		for _, r := range o.reporters {
+			r.PostEvaluate(o, o.Winners[0])
+		}
+
+		reason := o.terminate()
+		if reason == StopFitnessThreshold {
+			for _, r := range o.reporters {
+				r.FoundSolution(o, o.Winners[0])
+			}
+		}
+		for _, r := range o.reporters {
+			r.EndGeneration(o)
 		}
-		if dis > o.Options.MaxDistance {
-			keep = 0
+		if reason != "" {
+			return o.best(), reason
 		}
-		last = o.Winners[0].Fitness
-		o.next(dis)
 	}
 
+	return o.best(), StopGenerations
+}
+
+// Context returns the context of the running RunContext
+func (o *Population) Context() context.Context {
+	if o.ctx == nil {
+		return context.Background()
+	}
+	return o.ctx
+}
+
+func (o *Population) best() *Genome {
+	if len(o.Winners) == 0 {
+		return nil
+	}
 	return o.Winners[0]
 }
+
+// breed replaces the evaluated genomes by the next generation
+func (o *Population) breed() {
+	if o.lastFitness < o.Winners[0].Fitness {
+		o.keep = o.Options.KeepWinner
+		o.dis = 0
+	} else {
+		o.dis++
+	}
+	if o.dis > o.Options.MaxDistance {
+		o.keep = 0
+	}
+	o.lastFitness = o.Winners[0].Fitness
+	if len(o.Species) == 0 {
+		o.genomes = nil
+		o.createGenome("")
+	} else {
+		o.next(o.dis)
+	}
+	o.generation++
+	o.evaluated = false
+
+	if o.Options.CheckpointInterval > 0 && o.generation%o.Options.CheckpointInterval == 0 {
+		if err := o.SaveCheckpointFile(o.Options.CheckpointPrefix + strconv.Itoa(o.generation)); err != nil {
+			log.Printf("neatgo: checkpoint: %v", err)
+		}
+	}
+}
+
+// Generation returns the number of the current generation
+func (o *Population) Generation() int {
+	return o.generation
+}
+
 func (o *Population) createGenome(initJSON string) {
 	for i := 0; i < o.genomeNumber; i++ {
 		g, _ := NewGenome(o)
@@ -80,6 +215,7 @@ func (o *Population) createGenome(initJSON string) {
 			g.init()
 		} else {
 			g.LoadJSON(initJSON)
+			o.syncInnovations(g)
 		}
 		o.genomes = append(o.genomes, g)
 	}
@@ -91,32 +227,72 @@ func (o *Population) sortWinners(n int) {
 	if len(o.Winners) > n {
 		o.Winners = o.Winners[:n]
 	}
+	pool := o.Options.WinnerPool
+	if pool < 1 {
+		pool = 1
+	}
 	sort.Sort(sort.Reverse(o.genomes))
-	o.Winners = append(o.Winners, o.genomes[:4]...)
+	if pool < len(o.genomes) {
+		o.Winners = append(o.Winners, o.genomes[:pool]...)
+	} else {
+		o.Winners = append(o.Winners, o.genomes...)
+	}
 	sort.Sort(sort.Reverse(o.Winners))
-	o.Winners = o.Winners[:4]
+	if len(o.Winners) > pool {
+		o.Winners = o.Winners[:pool]
+	}
 }
 func (o *Population) next(dis int) {
-	// sum := 0.0
-	// for i := range o.genomes {
-	// 	sum += math.Pow(o.fitnessThreshold-o.genomes[i].Fitness, 2)
-	// }
-	// stdev := math.Sqrt(sum / (float64(len(o.genomes))))
-	// minStdev := stdev / o.fitnessThreshold
-
-	var a, b *Genome
-	for i := range o.genomes {
-		if i < 4 {
-			a = o.Winners[0].clone()
-			b = o.Winners[1].clone()
-			o.genomes[i] = a.crossover(b)
-		} else if i < o.genomeNumber-2 {
-			a = o.Winners[RandIntn(0, 3)].clone()
-			b = o.Winners[RandIntn(0, 3)].clone()
-			o.genomes[i] = a.crossover(b)
-		} else {
-			o.genomes[i] = o.Winners[RandIntn(0, 3)].clone()
+	if !o.Options.PersistInnovations {
+		o.innovations.reset()
+	}
+	o.shareFitness()
+	spawns := o.spawnCounts()
+
+	selector := o.Selector
+	if selector == nil {
+		selector = TruncationSelector{}
+	}
+
+	elites := make([]int, len(o.Species))
+	total, kept := 0, 0
+	for i, s := range o.Species {
+		elites[i] = o.Options.Elitism
+		if elites[i] > spawns[i] {
+			elites[i] = spawns[i]
+		}
+		if elites[i] > len(s.Members) {
+			elites[i] = len(s.Members)
+		}
+		total += spawns[i]
+		kept += elites[i]
+	}
+	// at least one offspring per generation is mutated, otherwise a population
+	// of elites never changes
+	for i := len(elites) - 1; i >= 0 && kept > 0 && kept >= total; i-- {
+		for elites[i] > 0 && kept >= total {
+			elites[i]--
+			kept--
+		}
+	}
+
+	genomes := Genomes{}
+	for i, s := range o.Species {
+		for n := 0; n < elites[i]; n++ {
+			genomes = append(genomes, s.Members[n].clone())
+		}
+
+		parents := s.survivors(o.Options.SurvivalThreshold)
+		for n := 0; n < spawns[i]-elites[i]; n++ {
+			g := selector.Select(o, parents)
+			if o.random(0, 1) < o.Options.CrossoverRate {
+				g = g.crossover(selector.Select(o, parents))
+			} else {
+				g = g.clone()
+			}
+			g.nextGeneration(dis)
+			genomes = append(genomes, g)
 		}
-		o.genomes[i].nextGeneration(i, dis)
 	}
+	o.genomes = genomes
 }
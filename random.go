@@ -0,0 +1,113 @@
+package neatgo
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// randSource is a splitmix64 generator. Unlike the math/rand source its
+// state is a single number, so it can be saved and restored.
+type randSource struct {
+	state uint64
+}
+
+func newRandSource(seed int64) *randSource {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &randSource{state: uint64(seed)}
+}
+
+// Seed ...
+func (o *randSource) Seed(seed int64) {
+	o.state = uint64(seed)
+}
+
+// Uint64 ...
+func (o *randSource) Uint64() uint64 {
+	o.state += 0x9e3779b97f4a7c15
+	z := o.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// Int63 ...
+func (o *randSource) Int63() int64 {
+	return int64(o.Uint64() >> 1)
+}
+
+func (o *Population) initRand() {
+	if o.Options.Source != nil {
+		o.rand = rand.New(o.Options.Source)
+		return
+	}
+	o.source = newRandSource(o.Options.Seed)
+	o.rand = rand.New(o.source)
+}
+
+// Rand returns the random generator of the population. It is not safe for
+// concurrent use.
+func (o *Population) Rand() *rand.Rand {
+	return o.rand
+}
+
+// random return min <= x < max, see NeatRandom
+func (o *Population) random(min, max float64) float64 {
+	if min == 0 && max == 0 {
+		return 0
+	}
+	return min + o.rand.Float64()*(max-min)
+}
+
+// randIntn return min <= x <= max, see RandIntn
+func (o *Population) randIntn(min, max int) int {
+	if min == 0 && max == 0 {
+		return 0
+	}
+	return o.rand.Intn(max+1-min) + min
+}
+
+// floatGene describes how a numeric gene is initialized and mutated
+type floatGene struct {
+	initType    string
+	mean, stdev float64
+	rate, power float64
+	replace     float64
+	mutateType  string
+	min, max    float64
+}
+
+// init returns a new value, gaussian of mean and stdev or uniform within two
+// stdev of the mean
+func (o floatGene) init(population *Population) float64 {
+	if o.initType == "uniform" {
+		return o.clamp(population.random(o.mean-2*o.stdev, o.mean+2*o.stdev))
+	}
+	return o.clamp(o.mean + population.rand.NormFloat64()*o.stdev)
+}
+
+// mutate perturbs v with chance rate by power, gaussian or uniform, or
+// replaces it by a new value with chance replace
+func (o floatGene) mutate(population *Population, v float64) float64 {
+	r := population.random(0, 1)
+	if r < o.rate {
+		if o.mutateType == "uniform" {
+			return o.clamp(v + population.random(-o.power, o.power))
+		}
+		return o.clamp(v + population.rand.NormFloat64()*o.power)
+	}
+	if r < o.rate+o.replace {
+		return o.init(population)
+	}
+	return v
+}
+
+// clamp limits v to [min, max], no limit when both are zero
+func (o floatGene) clamp(v float64) float64 {
+	if o.min == 0 && o.max == 0 {
+		return v
+	}
+	return math.Max(o.min, math.Min(o.max, v))
+}
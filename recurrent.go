@@ -0,0 +1,111 @@
+package neatgo
+
+// RecurrentNetwork is a stateful network that may contain cycles. Every call
+// of Activate propagates one step, reading the node values of the previous step
+type RecurrentNetwork struct {
+	inputs  []int
+	outputs []int
+	nodes   []recurrentNode
+	values  []float64
+	next    []float64
+	scratch []float64
+}
+
+type recurrentNode struct {
+	index     int
+	activate  func(x float64) float64
+	aggregate func(xs []float64) float64
+	bias      float64
+	response  float64
+	links     []recurrentLink
+}
+
+type recurrentLink struct {
+	in     int
+	weight float64
+}
+
+// NewRecurrentNetwork ...
+func NewRecurrentNetwork(genome *Genome) *RecurrentNetwork {
+	ids := genome.nodeIDs()
+
+	o := &RecurrentNetwork{
+		values: make([]float64, len(ids)),
+		next:   make([]float64, len(ids)),
+	}
+	index := make(map[int]int, len(ids))
+	for i, id := range ids {
+		index[id] = i
+	}
+
+	for i, id := range ids {
+		node := genome.Nodes[id]
+		switch node.Type {
+		case NodeTypeInput:
+			o.inputs = append(o.inputs, i)
+			continue
+		case NodeTypeOutput:
+			o.outputs = append(o.outputs, i)
+		}
+
+		rn := recurrentNode{
+			index:     i,
+			activate:  activateFunc[node.Activate],
+			aggregate: aggregation(node.Aggregation),
+			bias:      node.Bias,
+			response:  node.Response,
+		}
+		for _, c := range genome.Connections {
+			if !c.Enabled || c.Out != id {
+				continue
+			}
+			if in, ok := index[c.In]; ok {
+				rn.links = append(rn.links, recurrentLink{in: in, weight: c.Weight})
+			}
+		}
+		o.nodes = append(o.nodes, rn)
+	}
+
+	return o
+}
+
+// Reset clears the state of all nodes
+func (o *RecurrentNetwork) Reset() {
+	for i := range o.values {
+		o.values[i] = 0
+		o.next[i] = 0
+	}
+}
+
+// Activate ...
+func (o *RecurrentNetwork) Activate(inputs []float64) []float64 {
+	for i, n := range o.inputs {
+		if i < len(inputs) {
+			o.values[n] = inputs[i]
+			o.next[n] = inputs[i]
+		}
+	}
+
+	for _, node := range o.nodes {
+		x := 0.0
+		if node.aggregate == nil {
+			for _, l := range node.links {
+				x += o.values[l.in] * l.weight
+			}
+		} else {
+			o.scratch = o.scratch[:0]
+			for _, l := range node.links {
+				o.scratch = append(o.scratch, o.values[l.in]*l.weight)
+			}
+			x = node.aggregate(o.scratch)
+		}
+		o.next[node.index] = node.activate(node.bias + node.response*x)
+	}
+	o.values, o.next = o.next, o.values
+
+	outputs := make([]float64, len(o.outputs))
+	for i, n := range o.outputs {
+		outputs[i] = o.values[n]
+	}
+	return outputs
+}
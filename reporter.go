@@ -0,0 +1,204 @@
+package neatgo
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Reporter receives the progress of Population.Run
+type Reporter interface {
+	StartGeneration(population *Population)
+	PostEvaluate(population *Population, best *Genome)
+	SpeciesStagnant(population *Population, species *Species)
+	FoundSolution(population *Population, best *Genome)
+	EndGeneration(population *Population)
+}
+
+// BaseReporter implements Reporter with no-ops, embed it to implement only
+// the hooks you need
+type BaseReporter struct{}
+
+// StartGeneration ...
+func (BaseReporter) StartGeneration(population *Population) {}
+
+// PostEvaluate ...
+func (BaseReporter) PostEvaluate(population *Population, best *Genome) {}
+
+// SpeciesStagnant ...
+func (BaseReporter) SpeciesStagnant(population *Population, species *Species) {}
+
+// FoundSolution ...
+func (BaseReporter) FoundSolution(population *Population, best *Genome) {}
+
+// EndGeneration ...
+func (BaseReporter) EndGeneration(population *Population) {}
+
+// AddReporter ...
+func (o *Population) AddReporter(reporter Reporter) {
+	o.reporters = append(o.reporters, reporter)
+}
+
+// Genomes returns the genomes of the current generation
+func (o *Population) Genomes() Genomes {
+	return o.genomes
+}
+
+// FitnessStats returns the mean and standard deviation of the fitness of the
+// current generation
+func (o *Population) FitnessStats() (mean, stdev float64) {
+	if len(o.genomes) == 0 {
+		return 0, 0
+	}
+	for _, g := range o.genomes {
+		mean += g.Fitness
+	}
+	mean /= float64(len(o.genomes))
+	for _, g := range o.genomes {
+		stdev += math.Pow(g.Fitness-mean, 2)
+	}
+	return mean, math.Sqrt(stdev / float64(len(o.genomes)))
+}
+
+// StdOutReporter prints the progress every Interval generations
+type StdOutReporter struct {
+	BaseReporter
+	Interval int
+	Writer   io.Writer
+
+	start time.Time
+}
+
+// NewStdOutReporter ...
+func NewStdOutReporter(interval int) *StdOutReporter {
+	return &StdOutReporter{Interval: interval, Writer: os.Stdout}
+}
+
+// StartGeneration ...
+func (o *StdOutReporter) StartGeneration(population *Population) {
+	o.start = time.Now()
+}
+
+// PostEvaluate ...
+func (o *StdOutReporter) PostEvaluate(population *Population, best *Genome) {
+	if o.Interval > 1 && population.Generation()%o.Interval != 0 {
+		return
+	}
+	mean, stdev := population.FitnessStats()
+	fmt.Fprintf(o.Writer, "generation:%d species:%d nodes:%d connections:%d/%d fitness:%.16f mean:%.6f stdev:%.6f time:%v\n",
+		population.Generation(), len(population.Species), best.GetActiveNodeNumber(), best.GetActiveConnectionNumber(), len(best.Connections), best.Fitness, mean, stdev, time.Since(o.start))
+}
+
+// SpeciesStagnant ...
+func (o *StdOutReporter) SpeciesStagnant(population *Population, species *Species) {
+	fmt.Fprintf(o.Writer, "generation:%d species %d is stagnant, size:%d fitness:%.16f\n", population.Generation(), species.ID, species.Size(), species.Fitness)
+}
+
+// FoundSolution ...
+func (o *StdOutReporter) FoundSolution(population *Population, best *Genome) {
+	fmt.Fprintf(o.Writer, "generation:%d solution nodes:%d connections:%d fitness:%.16f\n", population.Generation(), best.GetActiveNodeNumber(), best.GetActiveConnectionNumber(), best.Fitness)
+}
+
+// CSVReporter writes one row of statistics per generation
+type CSVReporter struct {
+	BaseReporter
+	writer *csv.Writer
+	header bool
+}
+
+// NewCSVReporter ...
+func NewCSVReporter(w io.Writer) *CSVReporter {
+	return &CSVReporter{writer: csv.NewWriter(w)}
+}
+
+// PostEvaluate ...
+func (o *CSVReporter) PostEvaluate(population *Population, best *Genome) {
+	if !o.header {
+		o.header = true
+		o.writer.Write([]string{"generation", "species", "best_fitness", "mean_fitness", "stdev_fitness", "nodes", "connections"})
+	}
+	mean, stdev := population.FitnessStats()
+	o.writer.Write([]string{
+		strconv.Itoa(population.Generation()),
+		strconv.Itoa(len(population.Species)),
+		strconv.FormatFloat(best.Fitness, 'g', -1, 64),
+		strconv.FormatFloat(mean, 'g', -1, 64),
+		strconv.FormatFloat(stdev, 'g', -1, 64),
+		strconv.Itoa(best.GetActiveNodeNumber()),
+		strconv.Itoa(best.GetActiveConnectionNumber()),
+	})
+	o.writer.Flush()
+}
+
+// JSONReporter writes every event as one line of JSON
+type JSONReporter struct {
+	encoder *json.Encoder
+}
+
+// NewJSONReporter ...
+func NewJSONReporter(w io.Writer) *JSONReporter {
+	return &JSONReporter{encoder: json.NewEncoder(w)}
+}
+
+type jsonEvent struct {
+	Event       string    `json:"event"`
+	Generation  int       `json:"generation"`
+	Time        time.Time `json:"time"`
+	Species     *int      `json:"species,omitempty"`
+	SpeciesID   *int      `json:"species_id,omitempty"`
+	SpeciesSize *int      `json:"species_size,omitempty"`
+	Fitness     *float64  `json:"fitness,omitempty"`
+	Mean        *float64  `json:"mean,omitempty"`
+	Stdev       *float64  `json:"stdev,omitempty"`
+	Nodes       *int      `json:"nodes,omitempty"`
+	Connections *int      `json:"connections,omitempty"`
+}
+
+func (o *JSONReporter) write(event string, population *Population, fill func(e *jsonEvent)) {
+	e := &jsonEvent{Event: event, Generation: population.Generation(), Time: time.Now()}
+	if fill != nil {
+		fill(e)
+	}
+	o.encoder.Encode(e)
+}
+
+func (o *JSONReporter) best(population *Population, best *Genome) func(e *jsonEvent) {
+	return func(e *jsonEvent) {
+		species, nodes, connections := len(population.Species), best.GetActiveNodeNumber(), best.GetActiveConnectionNumber()
+		mean, stdev := population.FitnessStats()
+		e.Species, e.Fitness, e.Mean, e.Stdev, e.Nodes, e.Connections = &species, &best.Fitness, &mean, &stdev, &nodes, &connections
+	}
+}
+
+// StartGeneration ...
+func (o *JSONReporter) StartGeneration(population *Population) {
+	o.write("start_generation", population, nil)
+}
+
+// PostEvaluate ...
+func (o *JSONReporter) PostEvaluate(population *Population, best *Genome) {
+	o.write("post_evaluate", population, o.best(population, best))
+}
+
+// SpeciesStagnant ...
+func (o *JSONReporter) SpeciesStagnant(population *Population, species *Species) {
+	o.write("species_stagnant", population, func(e *jsonEvent) {
+		id, size := species.ID, species.Size()
+		e.SpeciesID, e.SpeciesSize, e.Fitness = &id, &size, &species.Fitness
+	})
+}
+
+// FoundSolution ...
+func (o *JSONReporter) FoundSolution(population *Population, best *Genome) {
+	o.write("found_solution", population, o.best(population, best))
+}
+
+// EndGeneration ...
+func (o *JSONReporter) EndGeneration(population *Population) {
+	o.write("end_generation", population, nil)
+}
@@ -0,0 +1,91 @@
+package neatgo
+
+import "math"
+
+// Selector chooses a parent among the surviving members of a species, sorted
+// by descending fitness
+type Selector interface {
+	Select(population *Population, candidates Genomes) *Genome
+}
+
+// TruncationSelector chooses uniformly among the best Threshold fraction of
+// the candidates, 0 means all of them
+type TruncationSelector struct {
+	Threshold float64
+}
+
+// Select ...
+func (o TruncationSelector) Select(population *Population, candidates Genomes) *Genome {
+	n := len(candidates)
+	if o.Threshold > 0 && o.Threshold < 1 {
+		n = int(math.Max(1, math.Ceil(o.Threshold*float64(n))))
+	}
+	return candidates[population.randIntn(0, n-1)]
+}
+
+// TournamentSelector chooses the fittest of Size random candidates
+type TournamentSelector struct {
+	Size int
+}
+
+// Select ...
+func (o TournamentSelector) Select(population *Population, candidates Genomes) *Genome {
+	best := candidates[population.randIntn(0, len(candidates)-1)]
+	for i := 1; i < o.Size; i++ {
+		if g := candidates[population.randIntn(0, len(candidates)-1)]; g.Fitness > best.Fitness {
+			best = g
+		}
+	}
+	return best
+}
+
+// RouletteSelector chooses proportionally to the fitness, shifted so that the
+// least fit candidate has zero weight
+type RouletteSelector struct{}
+
+// Select ...
+func (RouletteSelector) Select(population *Population, candidates Genomes) *Genome {
+	min := math.Inf(1)
+	for _, g := range candidates {
+		min = math.Min(min, g.Fitness)
+	}
+	weights := make([]float64, len(candidates))
+	for i, g := range candidates {
+		weights[i] = g.Fitness - min
+	}
+	return candidates[spin(population, weights)]
+}
+
+// RankSelector chooses proportionally to the rank, the fittest of n
+// candidates has weight n and the least fit 1
+type RankSelector struct{}
+
+// Select ...
+func (RankSelector) Select(population *Population, candidates Genomes) *Genome {
+	weights := make([]float64, len(candidates))
+	for i := range candidates {
+		weights[i] = float64(len(candidates) - i)
+	}
+	return candidates[spin(population, weights)]
+}
+
+// spin returns an index with probability proportional to its weight, uniform
+// when all weights are zero
+func spin(population *Population, weights []float64) int {
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+	if total <= 0 {
+		return population.randIntn(0, len(weights)-1)
+	}
+
+	r := population.random(0, total)
+	for i, w := range weights {
+		if r < w {
+			return i
+		}
+		r -= w
+	}
+	return len(weights) - 1
+}
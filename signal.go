@@ -0,0 +1,38 @@
+package neatgo
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// InterruptContext returns a context canceled on SIGINT or SIGTERM
+func InterruptContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-c:
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(c)
+	}()
+	return ctx, cancel
+}
+
+// RunInterruptible is Run stopping gracefully on SIGINT or SIGTERM. When
+// interrupted it saves a checkpoint to checkpointFile, resume it with
+// LoadCheckpointFile.
+func (o *Population) RunInterruptible(fitnessFunction FitnessFunction, generations int, initJSON, checkpointFile string) (*Genome, StopReason, error) {
+	ctx, cancel := InterruptContext(context.Background())
+	defer cancel()
+
+	winner, reason := o.RunContext(ctx, fitnessFunction, generations, initJSON)
+	if reason == StopCanceled {
+		return winner, reason, o.SaveCheckpointFile(checkpointFile)
+	}
+	return winner, reason, nil
+}
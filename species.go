@@ -0,0 +1,194 @@
+package neatgo
+
+import (
+	"math"
+	"sort"
+)
+
+// Species ...
+type Species struct {
+	ID              int
+	Representative  *Genome
+	Members         Genomes
+	Fitness         float64 // best raw fitness of the members
+	AdjustedFitness float64 // sum of the members' shared fitness
+	BestFitness     float64 // best fitness ever reached
+	LastImproved    int     // generation BestFitness was reached
+}
+
+// Stagnation returns the number of generations since the species improved
+func (o *Species) Stagnation(generation int) int {
+	return generation - o.LastImproved
+}
+
+// Size ...
+func (o *Species) Size() int {
+	return len(o.Members)
+}
+
+// survivors returns the best fraction of the members allowed to reproduce, at
+// least one
+func (o *Species) survivors(threshold float64) Genomes {
+	n := len(o.Members)
+	if threshold > 0 && threshold < 1 {
+		n = int(math.Max(1, math.Ceil(threshold*float64(n))))
+	}
+	return o.Members[:n]
+}
+
+// Distance returns the NEAT compatibility distance between two genomes
+func (o *Genome) Distance(b *Genome) float64 {
+	options := o.Population.Options
+	ac, bc := o.sortedConnections(), b.sortedConnections()
+
+	disjoint, matching, weight := 0, 0, 0.0
+	i, j := 0, 0
+	for i < len(ac) && j < len(bc) {
+		switch {
+		case ac[i].Innovation == bc[j].Innovation:
+			weight += math.Abs(ac[i].Weight - bc[j].Weight)
+			matching++
+			i++
+			j++
+		case ac[i].Innovation < bc[j].Innovation:
+			disjoint++
+			i++
+		default:
+			disjoint++
+			j++
+		}
+	}
+	excess := len(ac) - i + len(bc) - j
+
+	n := math.Max(float64(len(ac)), float64(len(bc)))
+	if n < 20 {
+		n = 1
+	}
+	d := (options.CompatibilityExcess*float64(excess) + options.CompatibilityDisjoint*float64(disjoint)) / n
+	if matching > 0 {
+		d += options.CompatibilityWeight * weight / float64(matching)
+	}
+	return d
+}
+
+func (o *Genome) sortedConnections() []*Connection {
+	cs := make([]*Connection, len(o.Connections))
+	copy(cs, o.Connections)
+	sort.Slice(cs, func(i, j int) bool { return cs[i].Innovation < cs[j].Innovation })
+	return cs
+}
+
+// speciate assigns every genome to the first species whose representative is
+// compatible, creating new species as needed and dropping empty ones
+func (o *Population) speciate() {
+	for _, s := range o.Species {
+		s.Members = Genomes{}
+	}
+
+	for _, g := range o.genomes {
+		var found *Species
+		for _, s := range o.Species {
+			if g.Distance(s.Representative) < o.Options.CompatibilityThreshold {
+				found = s
+				break
+			}
+		}
+		if found == nil {
+			found = &Species{ID: o.nextSpeciesID, Representative: g, BestFitness: g.Fitness, LastImproved: o.generation}
+			o.nextSpeciesID++
+			o.Species = append(o.Species, found)
+		}
+		found.Members = append(found.Members, g)
+	}
+
+	species := []*Species{}
+	for _, s := range o.Species {
+		if len(s.Members) == 0 {
+			continue
+		}
+		sort.Sort(sort.Reverse(s.Members))
+		s.Fitness = s.Members[0].Fitness
+		if s.Fitness > s.BestFitness {
+			s.BestFitness = s.Fitness
+			s.LastImproved = o.generation
+		}
+		s.Representative = s.Members[o.randIntn(0, len(s.Members)-1)]
+		species = append(species, s)
+	}
+	o.Species = species
+}
+
+// removeStagnant removes the species that did not improve for MaxStagnation
+// generations, except the SpeciesElitism best species
+func (o *Population) removeStagnant() {
+	if o.Options.MaxStagnation <= 0 {
+		return
+	}
+
+	sort.SliceStable(o.Species, func(i, j int) bool { return o.Species[i].Fitness > o.Species[j].Fitness })
+	species := []*Species{}
+	for i, s := range o.Species {
+		if i < o.Options.SpeciesElitism || s.Stagnation(o.generation) < o.Options.MaxStagnation {
+			species = append(species, s)
+			continue
+		}
+		for _, r := range o.reporters {
+			r.SpeciesStagnant(o, s)
+		}
+	}
+	o.Species = species
+}
+
+// shareFitness divides every genome's fitness by the size of its species
+func (o *Population) shareFitness() {
+	min := math.Inf(1)
+	for _, g := range o.genomes {
+		min = math.Min(min, g.Fitness)
+	}
+
+	for _, s := range o.Species {
+		s.AdjustedFitness = 0
+		for _, g := range s.Members {
+			g.AdjustedFitness = (g.Fitness - min) / float64(len(s.Members))
+			s.AdjustedFitness += g.AdjustedFitness
+		}
+	}
+}
+
+// spawnCounts returns the number of offspring of every species, proportional
+// to its adjusted fitness and summing up to genomeNumber
+func (o *Population) spawnCounts() []int {
+	spawns := make([]int, len(o.Species))
+	if len(o.Species) == 0 {
+		return spawns
+	}
+
+	total := 0.0
+	for _, s := range o.Species {
+		total += s.AdjustedFitness
+	}
+
+	remainders := make([]float64, len(o.Species))
+	sum := 0
+	for i, s := range o.Species {
+		share := float64(o.genomeNumber) / float64(len(o.Species))
+		if total > 0 {
+			share = s.AdjustedFitness / total * float64(o.genomeNumber)
+		}
+		spawns[i] = int(math.Floor(share))
+		remainders[i] = share - float64(spawns[i])
+		sum += spawns[i]
+	}
+
+	// largest remainder first
+	order := make([]int, len(o.Species))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return remainders[order[a]] > remainders[order[b]] })
+	for i := 0; sum < o.genomeNumber; i++ {
+		spawns[order[i%len(order)]]++
+		sum++
+	}
+	return spawns
+}
@@ -0,0 +1,159 @@
+package neatgo
+
+import (
+	"strings"
+	"time"
+)
+
+// ...
+const (
+	StopTimeBudget     StopReason = "time budget"
+	StopMaxEvaluations StopReason = "max evaluations"
+	StopNoImprovement  StopReason = "no improvement"
+	StopSpeciesExtinct StopReason = "species extinct"
+	StopMeanFitness    StopReason = "mean fitness"
+	StopPredicate      StopReason = "predicate"
+)
+
+// TerminationCriterion is consulted by Run after every evaluated generation
+type TerminationCriterion interface {
+	// Terminate returns why to stop, "" to continue
+	Terminate(population *Population) StopReason
+}
+
+// AddTermination adds a criterion, Run stops when any criterion is met. The
+// fitnessThreshold of NewPopulation is always consulted as FitnessThreshold.
+func (o *Population) AddTermination(criterion TerminationCriterion) {
+	o.terminations = append(o.terminations, criterion)
+}
+
+func (o *Population) terminate() StopReason {
+	criteria := AnyOf{FitnessThreshold(o.fitnessThreshold)}
+	if !o.Options.ResetOnExtinction {
+		criteria = append(criteria, SpeciesExtinct{})
+	}
+	return append(criteria, o.terminations...).Terminate(o)
+}
+
+// Elapsed returns the time since the running RunContext started
+func (o *Population) Elapsed() time.Duration {
+	return time.Since(o.started)
+}
+
+// Evaluations returns the number of genome evaluations so far
+func (o *Population) Evaluations() int {
+	return o.evaluations
+}
+
+// GenerationsWithoutImprovement returns the number of generations since the
+// best fitness improved
+func (o *Population) GenerationsWithoutImprovement() int {
+	return o.generation - o.lastImprovement
+}
+
+// FitnessThreshold stops when the best fitness reaches the threshold
+type FitnessThreshold float64
+
+// Terminate ...
+func (o FitnessThreshold) Terminate(population *Population) StopReason {
+	if best := population.best(); best != nil && best.Fitness >= float64(o) {
+		return StopFitnessThreshold
+	}
+	return ""
+}
+
+// TimeBudget stops when the run took longer than the duration
+type TimeBudget time.Duration
+
+// Terminate ...
+func (o TimeBudget) Terminate(population *Population) StopReason {
+	if population.Elapsed() >= time.Duration(o) {
+		return StopTimeBudget
+	}
+	return ""
+}
+
+// MaxEvaluations stops after the number of genome evaluations
+type MaxEvaluations int
+
+// Terminate ...
+func (o MaxEvaluations) Terminate(population *Population) StopReason {
+	if population.Evaluations() >= int(o) {
+		return StopMaxEvaluations
+	}
+	return ""
+}
+
+// NoImprovement stops when the best fitness did not improve for the number
+// of generations
+type NoImprovement int
+
+// Terminate ...
+func (o NoImprovement) Terminate(population *Population) StopReason {
+	if population.GenerationsWithoutImprovement() >= int(o) {
+		return StopNoImprovement
+	}
+	return ""
+}
+
+// SpeciesExtinct stops when no species is left
+type SpeciesExtinct struct{}
+
+// Terminate ...
+func (SpeciesExtinct) Terminate(population *Population) StopReason {
+	if len(population.Species) == 0 {
+		return StopSpeciesExtinct
+	}
+	return ""
+}
+
+// MeanFitness stops when the mean fitness of the generation reaches the target
+type MeanFitness float64
+
+// Terminate ...
+func (o MeanFitness) Terminate(population *Population) StopReason {
+	if mean, _ := population.FitnessStats(); mean >= float64(o) {
+		return StopMeanFitness
+	}
+	return ""
+}
+
+// Predicate stops when the function returns true
+type Predicate func(population *Population) bool
+
+// Terminate ...
+func (o Predicate) Terminate(population *Population) StopReason {
+	if o(population) {
+		return StopPredicate
+	}
+	return ""
+}
+
+// AnyOf stops when any criterion is met
+type AnyOf []TerminationCriterion
+
+// Terminate ...
+func (o AnyOf) Terminate(population *Population) StopReason {
+	for _, c := range o {
+		if reason := c.Terminate(population); reason != "" {
+			return reason
+		}
+	}
+	return ""
+}
+
+// AllOf stops when all criteria are met
+type AllOf []TerminationCriterion
+
+// Terminate ...
+func (o AllOf) Terminate(population *Population) StopReason {
+	reasons := []string{}
+	for _, c := range o {
+		reason := c.Terminate(population)
+		if reason == "" {
+			return ""
+		}
+		reasons = append(reasons, string(reason))
+	}
+	return StopReason(strings.Join(reasons, ", "))
+}